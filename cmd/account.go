@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/youtube/v3"
+	"sykesdev.ca/yls/pkg/client"
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/state"
+	"sykesdev.ca/yls/pkg/stream"
+)
+
+// openStateStore opens the configured state store. No store is returned when state tracking is disabled
+func openStateStore() (*state.Store, error) {
+	if stateFile == "" {
+		return nil, nil
+	}
+	return state.Open(stateFile)
+}
+
+// newTokenStore creates the TokenStore of the account, falling back to the top-level CLI flags. A nil account is the
+// default account configured by the top-level CLI flags
+func newTokenStore(account *stream.Account) (client.TokenStore, error) {
+	kind, location := tokenStoreKind, secretsCache
+	if account != nil {
+		if account.TokenStore != "" {
+			kind = account.TokenStore
+		}
+		location = account.SecretsCache
+		if location == "" {
+			location = secretsCache + "." + account.Name
+		}
+	}
+	if !helper.StringInSlice(kind, client.TOKEN_STORES_ALLOWED) {
+		return nil, fmt.Errorf("invalid token store %q. must be one of [%s]", kind, strings.Join(client.TOKEN_STORES_ALLOWED, ", "))
+	}
+
+	var passphrase []byte
+	if kind == client.TOKEN_STORE_ENCRYPTED {
+		passphrase = []byte(os.Getenv(client.TOKEN_PASSPHRASE_ENV))
+		if tokenKeyFile != "" {
+			b, err := os.ReadFile(tokenKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read token key file. %w", err)
+			}
+			passphrase = bytes.TrimRight(b, "\r\n")
+		}
+	}
+
+	if kind == client.TOKEN_STORE_KEYRING {
+		location = client.KEYRING_DEFAULT_USER
+		if account != nil {
+			location = account.Name
+		}
+	}
+	return client.NewTokenStore(kind, location, passphrase)
+}
+
+// errNoCredentials is returned when the default account is used without --oauth-config or --credentials
+var errNoCredentials = errors.New("no credentials are configured for the default account. specify --oauth-config or --credentials, or use an account from the streams input file")
+
+// newAccountUploader creates a StreamUploadClient for the account. A nil account uses the top-level CLI flags
+func newAccountUploader(ctx context.Context, account *stream.Account, store *state.Store) (*stream.StreamUploadClient, error) {
+	oauthConfig, credentials := oauthConfigFile, credentialsFile
+	if account != nil {
+		oauthConfig, credentials = account.OauthConfig, account.Credentials
+	} else if credentials == "" && nonInteractive && oauthConfig == "" {
+		credentials = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if account == nil && oauthConfig == "" && credentials == "" {
+		return nil, errNoCredentials
+	}
+
+	tokenStore, err := newTokenStore(account)
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.New(&stream.StreamUploaderConfig{
+		Context:        ctx,
+		OauthConfig:    oauthConfig,
+		TokenStore:     tokenStore,
+		LoginMode:      loginMode,
+		Credentials:    credentials,
+		NonInteractive: nonInteractive,
+		Scopes:         []string{youtube.YoutubeScope},
+		DryRunMode:     dryRun,
+		State:          store,
+		Retry:          retryPolicy(),
+	})
+}
+
+// retryPolicy builds the RetryPolicy configured by the top-level CLI flags
+func retryPolicy() *stream.RetryPolicy {
+	policy := stream.DefaultRetryPolicy
+	policy.Attempts = retryAttempts
+	policy.InitialBackoff = retryBackoff
+	policy.QuotaRetryAfter = quotaRetryAfter
+	return &policy
+}
+
+// newStreamUploader creates a StreamUploadClient for the account selected on the command line
+func newStreamUploader(ctx context.Context, account *stream.Account) (*stream.StreamUploadClient, error) {
+	store, err := openStateStore()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open state store %s. %w", stateFile, err)
+	}
+	return newAccountUploader(ctx, account, store)
+}
+
+// selectAccount resolves the account named by --account or, when none is given, the account of the named stream. The
+// streams input file is only required when an account has to be looked up
+func selectAccount(streams *stream.StreamList, streamName string) (*stream.Account, error) {
+	if accountName == "" && (streamName == "" || streamConfigFile == "") {
+		return nil, nil
+	}
+	if streams == nil {
+		if streamConfigFile == "" {
+			return nil, errors.New("the streams input file is required to select an account. specify --input")
+		}
+		var err error
+		if streams, err = getStreamsFromFile(); err != nil {
+			return nil, fmt.Errorf("unable to get streams from input file %s. %w", streamConfigFile, err)
+		}
+	}
+
+	name := accountName
+	if name == "" {
+		s := streams.Find(streamName)
+		if s == nil {
+			return nil, nil
+		}
+		name = s.Account
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	account := streams.FindAccount(name)
+	if account == nil {
+		return nil, fmt.Errorf("no account named %q is configured in %s", name, streamConfigFile)
+	}
+	return account, nil
+}
+
+// uploaderPool creates one StreamUploadClient per account and reuses it for every stream of the account. All of the
+// clients share a single state store
+type uploaderPool struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	store   *state.Store
+	clients map[string]*pooledUploader
+}
+
+type pooledUploader struct {
+	account *stream.Account
+	client  *stream.StreamUploadClient
+}
+
+func newUploaderPool(ctx context.Context) (*uploaderPool, error) {
+	store, err := openStateStore()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open state store %s. %w", stateFile, err)
+	}
+	return &uploaderPool{
+		ctx:     ctx,
+		store:   store,
+		clients: map[string]*pooledUploader{},
+	}, nil
+}
+
+// get returns the client of the account, creating it (and logging in) if the account is new or its configuration has
+// changed. A nil account is the default account configured by the top-level CLI flags
+func (p *uploaderPool) get(account *stream.Account) (*stream.StreamUploadClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	name := ""
+	if account != nil {
+		name = account.Name
+	}
+	if pooled, ok := p.clients[name]; ok && reflect.DeepEqual(pooled.account, account) {
+		return pooled.client, nil
+	}
+
+	c, err := newAccountUploader(p.ctx, account, p.store)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client for account %q. %w", name, err)
+	}
+	YLSLogger().Info("initialized Youtube Stream Uploader Client for account", zap.String("account", name))
+
+	var copied *stream.Account
+	if account != nil {
+		a := *account
+		copied = &a
+	}
+	p.clients[name] = &pooledUploader{account: copied, client: c}
+	return c, nil
+}
+
+// forStream returns the client of the account of the stream. Streams which reference an account that is not configured
+// are rejected rather than using the default credentials
+func (p *uploaderPool) forStream(streams *stream.StreamList, s *stream.Stream) (*stream.StreamUploadClient, error) {
+	account := streams.FindAccount(s.Account)
+	if s.Account != "" && account == nil {
+		return nil, fmt.Errorf("stream %s references unknown account %q", s.Name, s.Account)
+	}
+	c, err := p.get(account)
+	if errors.Is(err, errNoCredentials) {
+		return nil, fmt.Errorf("stream %s has no account and %w", s.Name, errNoCredentials)
+	}
+	return c, err
+}
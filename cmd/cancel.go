@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"sykesdev.ca/yls/pkg/stream"
+)
+
+const DATE_FORMAT = "2006-01-02"
+
+var cancelStreamName string
+var cancelFrom string
+var cancelTo string
+var cancelUnpublish bool
+
+var cancelCmd = &cobra.Command{
+	Use:     "cancel [broadcastID...]",
+	Aliases: []string{"delete"},
+	Short:   "deletes scheduled (upcoming) broadcasts",
+	Long:    "deletes scheduled (upcoming) broadcasts\n\nBroadcasts are selected by ID, by the name of the configured stream that created them and/or by a range of scheduled start dates. Optionally, the publisher configured for the stream can unpublish the broadcast as well",
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := &stream.CancelFilter{
+			BroadcastIDs: args,
+			StreamName:   cancelStreamName,
+		}
+
+		var err error
+		if filter.From, err = parseDate(cancelFrom, false); err != nil {
+			YLSLogger().Fatal("invalid --from date", zap.String("from", cancelFrom), zap.Error(err))
+		}
+		if filter.To, err = parseDate(cancelTo, true); err != nil {
+			YLSLogger().Fatal("invalid --to date", zap.String("to", cancelTo), zap.Error(err))
+		}
+
+		var streams *stream.StreamList
+		if cancelUnpublish {
+			if streamConfigFile == "" {
+				YLSLogger().Fatal("the streams input file is required to unpublish broadcasts. specify --input")
+			}
+			if streams, err = getStreamsFromFile(); err != nil {
+				YLSLogger().Fatal("unable to get streams from input file", zap.String("file", streamConfigFile), zap.Error(err))
+			}
+		}
+
+		account, err := selectAccount(streams, cancelStreamName)
+		if err != nil {
+			YLSLogger().Fatal("unable to select account", zap.String("account", accountName), zap.Error(err))
+		}
+		streamUploader, err := newStreamUploader(context.Background(), account)
+		if err != nil {
+			YLSLogger().Fatal("failed to initialize Youtube Stream Uploader Client", zap.Error(err))
+		}
+
+		cancelled, err := streamUploader.Cancel(filter, streams, cancelUnpublish)
+		if perr := printBroadcasts(cancelled, OUTPUT_FORMAT_TABLE); perr != nil {
+			YLSLogger().Error("unable to print cancelled broadcasts", zap.Error(perr))
+		}
+		if err != nil {
+			YLSLogger().Fatal("failed to cancel broadcasts", zap.Error(err))
+		}
+
+		YLSLogger().Info("cancelled matching broadcasts", zap.Int("count", len(cancelled)), zap.Bool("dryRun", dryRun))
+	},
+}
+
+// parseDate parses a date (in DATE_FORMAT) or timestamp (in RFC3339) from the CLI. When endOfDay is true, dates without
+// a time refer to the end of that day so that date ranges are inclusive
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(DATE_FORMAT, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date formatted as %s or an RFC3339 timestamp", DATE_FORMAT)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+func init() {
+	cancelCmd.Flags().StringVar(&cancelStreamName, "stream", "", "only cancel broadcasts created for the stream with the given name")
+	cancelCmd.Flags().StringVar(&cancelFrom, "from", "", fmt.Sprintf("only cancel broadcasts scheduled to start on or after this date (%s) or RFC3339 timestamp", DATE_FORMAT))
+	cancelCmd.Flags().StringVar(&cancelTo, "to", "", fmt.Sprintf("only cancel broadcasts scheduled to start on or before this date (%s) or RFC3339 timestamp", DATE_FORMAT))
+	cancelCmd.Flags().BoolVar(&cancelUnpublish, "unpublish", false, "also unpublish each cancelled broadcast using the publisher configured for its stream. requires --input")
+	cancelCmd.Flags().StringVarP(&streamConfigFile, "input", "i", "", "the path to the file which specifies configuration for youtube stream schedules")
+
+	rootCmd.AddCommand(cancelCmd)
+}
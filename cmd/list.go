@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/stream"
+)
+
+const (
+	OUTPUT_FORMAT_TABLE = "table"
+	OUTPUT_FORMAT_JSON  = "json"
+	OUTPUT_FORMAT_YAML  = "yaml"
+)
+
+var OUTPUT_FORMATS_ALLOWED = []string{OUTPUT_FORMAT_TABLE, OUTPUT_FORMAT_JSON, OUTPUT_FORMAT_YAML}
+
+var listStatus string
+var listStreamName string
+var listFormat string
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "lists the live broadcasts of the authenticated channel",
+	Long:  "lists the live broadcasts of the authenticated channel\n\nBroadcasts can be filtered by their status and by the name of the configured stream that created them",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !helper.StringInSlice(listFormat, OUTPUT_FORMATS_ALLOWED) {
+			YLSLogger().Fatal("invalid output format", zap.String("format", listFormat), zap.Strings("allowed", OUTPUT_FORMATS_ALLOWED))
+		}
+		var streams *stream.StreamList
+		if listStreamName != "" && streamConfigFile != "" {
+			var err error
+			streams, err = getStreamsFromFile()
+			if err != nil {
+				YLSLogger().Fatal("unable to get streams from input file", zap.String("file", streamConfigFile), zap.Error(err))
+			}
+			if streams.Find(listStreamName) == nil {
+				YLSLogger().Fatal("no stream with the given name exists in the input file", zap.String("streamName", listStreamName), zap.String("file", streamConfigFile))
+			}
+		}
+
+		account, err := selectAccount(streams, listStreamName)
+		if err != nil {
+			YLSLogger().Fatal("unable to select account", zap.String("account", accountName), zap.Error(err))
+		}
+		streamUploader, err := newStreamUploader(context.Background(), account)
+		if err != nil {
+			YLSLogger().Fatal("failed to initialize Youtube Stream Uploader Client", zap.Error(err))
+		}
+
+		broadcasts, err := streamUploader.List(listStatus, listStreamName)
+		if err != nil {
+			YLSLogger().Fatal("unable to list broadcasts", zap.Error(err))
+		}
+
+		if err := printBroadcasts(broadcasts, listFormat); err != nil {
+			YLSLogger().Fatal("unable to print broadcasts", zap.Error(err))
+		}
+	},
+}
+
+func printBroadcasts(broadcasts []*stream.BroadcastSummary, format string) error {
+	switch format {
+	case OUTPUT_FORMAT_JSON:
+		b, err := json.MarshalIndent(broadcasts, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case OUTPUT_FORMAT_YAML:
+		b, err := yaml.Marshal(broadcasts)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(b))
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTREAM\tTITLE\tSTATUS\tSCHEDULED START\tPRIVACY\tSTREAM KEY\tSHARE LINK\tEMBED LINK")
+		for _, b := range broadcasts {
+			fmt.Fprintln(w, strings.Join([]string{
+				b.ID,
+				b.StreamName,
+				b.Title,
+				b.Status,
+				b.ScheduledStart,
+				b.Privacy,
+				b.BoundStreamKey,
+				b.ShareableLink,
+				b.EmbedableLink,
+			}, "\t"))
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+func init() {
+	listCmd.Flags().StringVar(&listStatus, "status", stream.BROADCAST_STATUS_UPCOMING, fmt.Sprintf("only list broadcasts with the given status. one of [%s]", strings.Join(stream.BROADCAST_STATUSES_ALLOWED, ", ")))
+	listCmd.Flags().StringVar(&listStreamName, "stream", "", "only list broadcasts created for the stream with the given name")
+	listCmd.Flags().StringVarP(&streamConfigFile, "input", "i", "", "the path to the file which specifies configuration for youtube stream schedules. when specified, --stream must name one of its streams")
+	listCmd.Flags().StringVar(&listFormat, "format", OUTPUT_FORMAT_TABLE, fmt.Sprintf("the output format. one of [%s]", strings.Join(OUTPUT_FORMATS_ALLOWED, ", ")))
+
+	rootCmd.AddCommand(listCmd)
+}
@@ -4,3 +4,14 @@ import "sykesdev.ca/yls/pkg/logging"
 
 // alias logger
 var YLSLogger = logging.YLSLogger
+
+// cronLogger logs the messages of the scheduler, such as the panics it recovers from, with the YLS logger
+type cronLogger struct{}
+
+func (cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	YLSLogger().Sugar().Debugw(msg, keysAndValues...)
+}
+
+func (cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	YLSLogger().Sugar().Errorw(msg, append(keysAndValues, "error", err)...)
+}
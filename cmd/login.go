@@ -16,28 +16,47 @@ var loginCmd = &cobra.Command{
 	Short: "forces a login and stores the resulting access token in the configured credentials cache",
 	Long:  "forces a login and stores the resulting access token in the configured credentials cache\n\nBy forcing a login, we can update credentials that are persisted to the disk before executing the program in a headless configuration",
 	Run: func(cmd *cobra.Command, args []string) {
-		YLSLogger().Debug("config", zap.String("oauth_config", oauthConfigFile))
-		if oauthConfigFile == "" {
-			YLSLogger().Fatal("oauth configuration file is required. specify --oauth-config")
+		account, err := selectAccount(nil, "")
+		if err != nil {
+			YLSLogger().Fatal("unable to select account", zap.String("account", accountName), zap.Error(err))
+		}
+		oauthConfig := oauthConfigFile
+		if account != nil {
+			oauthConfig = account.OauthConfig
 		}
-		b, err := os.ReadFile(oauthConfigFile)
+
+		YLSLogger().Debug("config", zap.String("oauth_config", oauthConfig), zap.String("account", accountName))
+		if oauthConfig == "" {
+			YLSLogger().Fatal("oauth configuration file is required. specify --oauth-config, or an oauthConfig for the account")
+		}
+		b, err := os.ReadFile(oauthConfig)
 		if err != nil {
 			YLSLogger().Fatal("unable to read oauth configuration from file", zap.Error(err))
 		}
 
+		// the redirect URI is chosen by the login flow, so the redirect_uris of the client secret file do not matter
 		config, err := google.ConfigFromJSON(b, youtube.YoutubeScope)
 		if err != nil {
 			YLSLogger().Fatal("unable to parse client secret file to config", zap.Error(err))
 		}
-		c := client.Get(context.TODO(), secretsCache, config)
-		if c == nil {
-			YLSLogger().Fatal("login failed")
+		tokenStore, err := newTokenStore(account)
+		if err != nil {
+			YLSLogger().Fatal("unable to open token store", zap.Error(err))
+		}
+		tok, err := client.Login(context.TODO(), config, loginMode)
+		if err != nil {
+			YLSLogger().Fatal("login failed", zap.Error(err))
+		}
+		if err := tokenStore.Save(tok); err != nil {
+			YLSLogger().Fatal("unable to store the token from the login", zap.Stringer("store", tokenStore), zap.Error(err))
 		}
 
-		YLSLogger().Info("login succeeded!")
+		YLSLogger().Info("login succeeded!", zap.String("account", accountName), zap.Stringer("store", tokenStore))
 	},
 }
 
 func init() {
+	loginCmd.Flags().StringVarP(&streamConfigFile, "input", "i", "", "the path to the file which specifies configuration for youtube stream schedules. required to log in to an --account")
+
 	rootCmd.AddCommand(loginCmd)
 }
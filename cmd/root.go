@@ -1,18 +1,33 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"sykesdev.ca/yls/pkg/client"
 	"sykesdev.ca/yls/pkg/logging"
+	"sykesdev.ca/yls/pkg/stream"
 )
 
 // top-level CLI vars
 var (
 	oauthConfigFile string
 	secretsCache    string
+	tokenStoreKind  string
+	tokenKeyFile    string
+	loginMode       string
+	credentialsFile string
+	nonInteractive  bool
+	accountName     string
+	retryAttempts   int
+	retryBackoff    time.Duration
+	quotaRetryAfter time.Duration
+	stateFile       string
 	loggingOut      string
 	dryRun          bool
 	debugMode       bool
@@ -36,12 +51,20 @@ func init() {
 	}
 
 	rootCmd.PersistentFlags().StringVar(&secretsCache, "secrets-cache", path.Join(homeDir, ".youtube_oauth2_credentials"), "A path to a file location that will be used to cache OAuth2.0 Access and Refresh Tokens")
-	rootCmd.PersistentFlags().StringVar(&oauthConfigFile, "oauth-config", "", "(required) the path to a JSON formatted Google Oauth2 configuration file used to configure the Oauth2 context/client for authentication/authorization")
+	rootCmd.PersistentFlags().StringVar(&tokenStoreKind, "token-store", client.TOKEN_STORE_FILE, fmt.Sprintf("Where OAuth2.0 tokens are cached. One of [%s]. The encrypted store uses the passphrase in %s or --token-key-file", strings.Join(client.TOKEN_STORES_ALLOWED, ", "), client.TOKEN_PASSPHRASE_ENV))
+	rootCmd.PersistentFlags().StringVar(&tokenKeyFile, "token-key-file", "", "A path to a file containing the passphrase of the encrypted token cache")
+	rootCmd.PersistentFlags().StringVar(&loginMode, "login-mode", client.LOGIN_MODE_BROWSER, fmt.Sprintf("How to log in when no token is cached. One of [%s]", strings.Join(client.LOGIN_MODES_ALLOWED, ", ")))
+	rootCmd.PersistentFlags().StringVar(&credentialsFile, "credentials", "", "A path to a GOOGLE_APPLICATION_CREDENTIALS-style JSON file (such as authorized_user credentials with a refresh token) used instead of --oauth-config and the token cache. Defaults to $GOOGLE_APPLICATION_CREDENTIALS in non-interactive mode when --oauth-config is not specified")
+	rootCmd.PersistentFlags().BoolVar(&nonInteractive, "non-interactive", false, "specifies whether YLS should fail with an error instead of asking to log in when there is no usable token. Use this for unattended deployments")
+	rootCmd.PersistentFlags().StringVar(&accountName, "account", "", "the name of the account (configured in the streams input file) to use instead of the top-level OAuth2.0 flags")
+	rootCmd.PersistentFlags().IntVar(&retryAttempts, "retry-attempts", stream.DefaultRetryPolicy.Attempts, "the number of attempts made for each YouTube API call that fails with a transient error (such as a 5xx or rate limit)")
+	rootCmd.PersistentFlags().DurationVar(&retryBackoff, "retry-backoff", stream.DefaultRetryPolicy.InitialBackoff, fmt.Sprintf("the backoff before the first retry of a failed YouTube API call. it doubles with each retry (with jitter), up to %s", stream.DefaultRetryPolicy.MaxBackoff))
+	rootCmd.PersistentFlags().DurationVar(&quotaRetryAfter, "quota-retry-after", 0, "re-attempt a scheduled job this long after it failed because the YouTube API quota was exhausted. 0 disables re-attempts")
+	rootCmd.PersistentFlags().StringVar(&stateFile, "state", path.Join(homeDir, ".yls_state.json"), "A path to a JSON file used to record every broadcast created by YLS. Set to an empty string to disable state tracking")
+	rootCmd.PersistentFlags().StringVar(&oauthConfigFile, "oauth-config", "", "the path to a JSON formatted Google Oauth2 configuration file used to configure the Oauth2 context/client for authentication/authorization")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "specifies whether YLS should be run in dry-run mode. This means YLS will make no changes, but will help evaluate changes that would be done")
 	rootCmd.PersistentFlags().BoolVar(&debugMode, "debug", false, "specifies whether Debug-level logs should be shown. This can be very noisy (be warned)")
 	rootCmd.PersistentFlags().StringVarP(&loggingOut, "out", "o", "", "specifies a file path to write logs to")
-
-	rootCmd.MarkFlagRequired("oauth-config")
 }
 
 func initLogging() {
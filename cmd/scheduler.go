@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"sykesdev.ca/yls/pkg/stream"
+)
+
+// RELOAD_DEBOUNCE groups the bursts of file events emitted by editors (and config map updates) into a single reload
+const RELOAD_DEBOUNCE = 500 * time.Millisecond
+
+// scheduledStream tracks the cron entries registered for a single stream
+type scheduledStream struct {
+	stream   *stream.Stream
+	uploader *stream.StreamUploadClient
+	upload   cron.EntryID
+	entries  []cron.EntryID
+}
+
+// scheduler registers the jobs of every configured stream and keeps them in sync with the streams configuration
+type scheduler struct {
+	mu        sync.Mutex
+	cron      *cron.Cron
+	uploaders *uploaderPool
+	jobs      map[string]*scheduledStream
+}
+
+// newScheduler creates a scheduler whose jobs recover from panics (such as in a publisher or template), so that a
+// single job cannot stop the scheduler
+func newScheduler(loc *time.Location, uploaders *uploaderPool) *scheduler {
+	return &scheduler{
+		cron:      cron.New(cron.WithLocation(loc), cron.WithChain(cron.Recover(cronLogger{}))),
+		uploaders: uploaders,
+		jobs:      map[string]*scheduledStream{},
+	}
+}
+
+// streamSchedules are the parsed schedules of every job of a stream. The end and max duration schedules are nil when
+// the stream does not configure them
+type streamSchedules struct {
+	job         cron.Schedule
+	end         cron.Schedule
+	maxDuration cron.Schedule
+}
+
+// parseSchedules parses every schedule of the stream, so that its jobs can be registered without failing
+func parseSchedules(s *stream.Stream) (*streamSchedules, error) {
+	var err error
+	scheds := &streamSchedules{}
+	if scheds.job, err = s.JobSchedule(); err != nil {
+		return nil, fmt.Errorf("failed to create scheduled job for stream %s. %w", s.Name, err)
+	}
+	if s.EndSchedule != "" {
+		if scheds.end, err = s.ParseSpec(s.EndSchedule); err != nil {
+			return nil, fmt.Errorf("failed to create scheduled completion job for stream %s. %w", s.Name, err)
+		}
+	}
+	if s.MaxDurationMinutes > 0 {
+		if scheds.maxDuration, err = s.MaxDurationSchedule(); err != nil {
+			return nil, fmt.Errorf("failed to create scheduled max duration job for stream %s. %w", s.Name, err)
+		}
+	}
+	return scheds, nil
+}
+
+// add registers every job for the stream with the client of its account. Schedules are parsed before any job is
+// registered so that a stream is either fully scheduled or not at all
+func (sc *scheduler) add(s *stream.Stream, uploader *stream.StreamUploadClient) error {
+	scheds, err := parseSchedules(s)
+	if err != nil {
+		return err
+	}
+	sc.register(s, uploader, scheds)
+	return nil
+}
+
+// register adds the jobs of the stream to the cron on its parsed schedules
+func (sc *scheduler) register(s *stream.Stream, uploader *stream.StreamUploadClient, scheds *streamSchedules) {
+	job := &scheduledStream{stream: s, uploader: uploader}
+	job.upload = sc.cron.Schedule(scheds.job, cron.FuncJob(uploader.Job(s)))
+	job.entries = append(job.entries, job.upload)
+	YLSLogger().Info("added new job to scheduler", zap.String("jobName", s.Name), zap.String("account", s.Account), zap.String("jobSchedule", s.Schedule), zap.Stringer("createAhead", s.CreateAhead), zap.String("timezone", s.Timezone))
+
+	if scheds.end != nil {
+		job.entries = append(job.entries, sc.cron.Schedule(scheds.end, cron.FuncJob(uploader.Complete(s))))
+		YLSLogger().Info("added new completion job to scheduler", zap.String("jobName", s.Name), zap.String("jobSchedule", s.EndSchedule))
+	}
+	if scheds.maxDuration != nil {
+		job.entries = append(job.entries, sc.cron.Schedule(scheds.maxDuration, cron.FuncJob(uploader.EnforceMaxDuration(s))))
+		YLSLogger().Info("added new max duration job to scheduler", zap.String("jobName", s.Name), zap.Uint("maxDurationMinutes", s.MaxDurationMinutes))
+	}
+
+	sc.jobs[s.Name] = job
+}
+
+// remove unregisters every job of the named stream. Jobs that are already running are allowed to complete
+func (sc *scheduler) remove(name string) {
+	job, ok := sc.jobs[name]
+	if !ok {
+		return
+	}
+	for _, id := range job.entries {
+		sc.cron.Remove(id)
+	}
+	job.uploader.CancelReattempt(name)
+	delete(sc.jobs, name)
+	YLSLogger().Info("removed jobs from scheduler", zap.String("jobName", name))
+}
+
+// resolveTimezones sets the time zone of the list on every stream which does not configure its own. The location of the
+// cron is only resolved at startup, so the schedules of a stream must not depend on it once the list time zone has been
+// changed or removed by a reload. The process-local time zone (Local) is used if the list does not configure one
+func resolveTimezones(streams *stream.StreamList) error {
+	loc, err := streams.Location()
+	if err != nil {
+		return fmt.Errorf("invalid time zone %q. %w", streams.Timezone, err)
+	}
+	for i := range streams.Items {
+		if streams.Items[i].Timezone == "" {
+			streams.Items[i].Timezone = loc.String()
+		}
+	}
+	return nil
+}
+
+// load registers the jobs of every stream in the list
+func (sc *scheduler) load(streams *stream.StreamList) error {
+	if err := resolveTimezones(streams); err != nil {
+		return err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for i := range streams.Items {
+		uploader, err := sc.uploaders.forStream(streams, &streams.Items[i])
+		if err != nil {
+			return err
+		}
+		if err := sc.add(&streams.Items[i], uploader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reload diffs the streams against the registered jobs and only adds, removes or replaces the jobs of streams
+// that changed (including streams whose account or time zone changed). Every schedule is parsed and every account is
+// logged in to before any job is changed, so that a reload is either applied in full or not at all
+func (sc *scheduler) reload(streams *stream.StreamList) error {
+	if err := resolveTimezones(streams); err != nil {
+		return err
+	}
+
+	uploaders := make([]*stream.StreamUploadClient, len(streams.Items))
+	scheds := make([]*streamSchedules, len(streams.Items))
+	for i := range streams.Items {
+		s := &streams.Items[i]
+		uploader, err := sc.uploaders.forStream(streams, s)
+		if err != nil {
+			return err
+		}
+		uploaders[i] = uploader
+		if scheds[i], err = parseSchedules(s); err != nil {
+			return err
+		}
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var added, removed, replaced, unchanged []string
+	configured := map[string]bool{}
+	for i := range streams.Items {
+		s := &streams.Items[i]
+		configured[s.Name] = true
+
+		existing, ok := sc.jobs[s.Name]
+		switch {
+		case !ok:
+			added = append(added, s.Name)
+		case !reflect.DeepEqual(existing.stream, s) || existing.uploader != uploaders[i]:
+			sc.remove(s.Name)
+			replaced = append(replaced, s.Name)
+		default:
+			unchanged = append(unchanged, s.Name)
+			continue
+		}
+		sc.register(s, uploaders[i], scheds[i])
+	}
+	for name := range sc.jobs {
+		if !configured[name] {
+			sc.remove(name)
+			removed = append(removed, name)
+		}
+	}
+
+	YLSLogger().Info("reloaded streams configuration",
+		zap.Strings("added", added),
+		zap.Strings("removed", removed),
+		zap.Strings("replaced", replaced),
+		zap.Strings("unchanged", unchanged),
+	)
+	sc.logNextRuns()
+	return nil
+}
+
+// logNextRuns logs the next run of each upload job in the time zone of its stream
+func (sc *scheduler) logNextRuns() {
+	for _, job := range sc.jobs {
+		next := sc.cron.Entry(job.upload).Next
+		if next.IsZero() {
+			continue
+		}
+		loc, err := job.stream.Location()
+		if err != nil {
+			continue
+		}
+		YLSLogger().Info("next scheduled run for stream",
+			zap.String("jobName", job.stream.Name),
+			zap.String("nextRun", next.In(loc).Format(time.RFC3339)),
+			zap.String("timezone", loc.String()),
+		)
+	}
+}
+
+func (sc *scheduler) start() {
+	sc.cron.Start()
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.logNextRuns()
+}
+
+func (sc *scheduler) stop() {
+	<-sc.cron.Stop().Done()
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for name, job := range sc.jobs {
+		job.uploader.CancelReattempt(name)
+	}
+}
+
+// watchFile calls onChange whenever the file at path is written, created or replaced. The parent directory is watched
+// so that editors which replace the file (and symlink swaps used by Kubernetes config maps) are detected as well
+func watchFile(path string, onChange func()) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		var debounce *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				if filepath.Clean(event.Name) != absPath && filepath.Base(event.Name) != "..data" {
+					continue
+				}
+				YLSLogger().Debug("detected change to streams configuration file", zap.String("event", event.String()))
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(RELOAD_DEBOUNCE, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				YLSLogger().Warn("error watching streams configuration file", zap.String("file", path), zap.Error(err))
+			}
+		}
+	}()
+
+	return watcher, nil
+}
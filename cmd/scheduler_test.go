@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"sykesdev.ca/yls/pkg/stream"
+)
+
+func TestSchedulerRecoversFromPanics(t *testing.T) {
+	sc := newScheduler(time.UTC, nil)
+	ran := false
+	id := sc.cron.Schedule(cron.Every(time.Hour), cron.FuncJob(func() {
+		ran = true
+		panic("publisher template failed")
+	}))
+
+	// the wrapped job is what the scheduler runs
+	sc.cron.Entry(id).WrappedJob.Run()
+	if !ran {
+		t.Error("expected the job to run")
+	}
+}
+
+// newTestScheduler creates a scheduler whose uploader pool already holds a client for the default account and for the
+// "youth" account, so that no account has to log in
+func newTestScheduler(t *testing.T) (*scheduler, map[string]*stream.StreamUploadClient) {
+	t.Helper()
+
+	pool := &uploaderPool{clients: map[string]*pooledUploader{}}
+	uploaders := map[string]*stream.StreamUploadClient{}
+	for _, account := range []*stream.Account{nil, testAccount()} {
+		u, err := stream.New(&stream.StreamUploaderConfig{Backend: stream.NewFakeBackend()})
+		if err != nil {
+			t.Fatalf("failed to create uploader. %s", err)
+		}
+		name := ""
+		if account != nil {
+			name = account.Name
+		}
+		pool.clients[name] = &pooledUploader{account: account, client: u}
+		uploaders[name] = u
+	}
+	return newScheduler(time.UTC, pool), uploaders
+}
+
+func testAccount() *stream.Account {
+	return &stream.Account{Name: "youth", OauthConfig: "youth_client_secret.json"}
+}
+
+func testStreams(timezone string, streams ...stream.Stream) *stream.StreamList {
+	sl := &stream.StreamList{Timezone: timezone, Accounts: []stream.Account{*testAccount()}, Items: streams}
+	sl.ApplyDefaults()
+	return sl
+}
+
+func testSchedulerStream(name, schedule string) stream.Stream {
+	return stream.Stream{Name: name, Title: name, Schedule: schedule}
+}
+
+func TestSchedulerReload(t *testing.T) {
+	sc, uploaders := newTestScheduler(t)
+	if err := sc.load(testStreams("UTC",
+		testSchedulerStream("unchanged", "0 9 * * 0"),
+		testSchedulerStream("edited", "0 10 * * 0"),
+		testSchedulerStream("moved", "0 11 * * 0"),
+		testSchedulerStream("removed", "0 12 * * 0"),
+	)); err != nil {
+		t.Fatalf("failed to load streams. %s", err)
+	}
+	before := map[string]cron.EntryID{}
+	for name, job := range sc.jobs {
+		before[name] = job.upload
+	}
+
+	edited := testSchedulerStream("edited", "0 10 * * 0")
+	edited.Title = "Edited"
+	moved := testSchedulerStream("moved", "0 11 * * 0")
+	moved.Account = "youth"
+	if err := sc.reload(testStreams("UTC",
+		testSchedulerStream("unchanged", "0 9 * * 0"),
+		edited,
+		moved,
+		testSchedulerStream("added", "0 13 * * 0"),
+	)); err != nil {
+		t.Fatalf("failed to reload streams. %s", err)
+	}
+
+	if _, ok := sc.jobs["removed"]; ok {
+		t.Error("expected the jobs of the removed stream to be unregistered")
+	}
+	if sc.cron.Entry(before["removed"]).ID != 0 {
+		t.Error("expected the cron entry of the removed stream to be removed")
+	}
+	if got := sc.jobs["unchanged"].upload; got != before["unchanged"] {
+		t.Errorf("expected the job of the unchanged stream to be kept, got entry %d instead of %d", got, before["unchanged"])
+	}
+	for _, name := range []string{"edited", "moved"} {
+		if got := sc.jobs[name].upload; got == before[name] {
+			t.Errorf("expected the job of %s to be replaced", name)
+		}
+	}
+	if sc.jobs["edited"].stream.Title != "Edited" {
+		t.Errorf("expected the replaced job to use the edited stream, got title %q", sc.jobs["edited"].stream.Title)
+	}
+	if sc.jobs["moved"].uploader != uploaders["youth"] {
+		t.Error("expected the stream which moved to another account to use the client of that account")
+	}
+	if _, ok := sc.jobs["added"]; !ok {
+		t.Error("expected the added stream to be scheduled")
+	}
+	if n := len(sc.cron.Entries()); n != 4 {
+		t.Errorf("expected 4 cron entries, got %d", n)
+	}
+}
+
+func TestSchedulerReloadWithInvalidStream(t *testing.T) {
+	sc, _ := newTestScheduler(t)
+	if err := sc.load(testStreams("UTC",
+		testSchedulerStream("edited", "0 9 * * 0"),
+		testSchedulerStream("removed", "0 10 * * 0"),
+	)); err != nil {
+		t.Fatalf("failed to load streams. %s", err)
+	}
+	before := map[string]*scheduledStream{}
+	for name, job := range sc.jobs {
+		copied := *job
+		before[name] = &copied
+	}
+
+	edited := testSchedulerStream("edited", "0 9 * * 0")
+	edited.Title = "Edited"
+	invalid := testSchedulerStream("invalid", "0 11 * * 0")
+	invalid.EndSchedule = "0 12 * *"
+	if err := sc.reload(testStreams("UTC", edited, testSchedulerStream("added", "0 13 * * 0"), invalid)); err == nil {
+		t.Fatal("expected the reload to fail")
+	}
+
+	if len(sc.jobs) != len(before) {
+		t.Errorf("expected the jobs of %d streams to stay registered, got %d", len(before), len(sc.jobs))
+	}
+	for name, job := range before {
+		got, ok := sc.jobs[name]
+		if !ok || got.stream != job.stream || got.upload != job.upload || !reflect.DeepEqual(got.entries, job.entries) {
+			t.Errorf("expected the jobs of %s to be left untouched, got %+v", name, got)
+		}
+	}
+	if n := len(sc.cron.Entries()); n != 2 {
+		t.Errorf("expected 2 cron entries, got %d", n)
+	}
+}
+
+func TestSchedulerReloadResolvesTimezone(t *testing.T) {
+	if time.Local.String() == "America/Toronto" {
+		t.Skip("the local time zone is the time zone being removed")
+	}
+	sc, _ := newTestScheduler(t)
+	if err := sc.load(testStreams("America/Toronto", testSchedulerStream("sunday", "0 9 * * 0"))); err != nil {
+		t.Skipf("time zone data is not available. %s", err)
+	}
+	before := sc.jobs["sunday"].upload
+
+	// removing the top-level time zone moves the stream to the process-local time zone, not the zone of the cron
+	if err := sc.reload(testStreams("", testSchedulerStream("sunday", "0 9 * * 0"))); err != nil {
+		t.Fatalf("failed to reload streams. %s", err)
+	}
+	job := sc.jobs["sunday"]
+	if job.upload == before {
+		t.Fatal("expected the job to be replaced when its time zone changed")
+	}
+	if job.stream.Timezone != time.Local.String() {
+		t.Fatalf("expected the stream in the local time zone %s, got %q", time.Local, job.stream.Timezone)
+	}
+
+	next := sc.cron.Entry(job.upload).Schedule.Next(time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC))
+	if local := next.In(time.Local); local.Hour() != 9 || local.Weekday() != time.Sunday {
+		t.Errorf("expected the job at 9:00 on Sunday in the local time zone, got %s", local)
+	}
+}
+
+func TestSchedulerLoadRejectsUnknownAccount(t *testing.T) {
+	sc, _ := newTestScheduler(t)
+	s := testSchedulerStream("sunday", "0 9 * * 0")
+	s.Account = "choir"
+
+	err := sc.load(testStreams("UTC", s))
+	if err == nil || err.Error() != `stream sunday references unknown account "choir"` {
+		t.Fatalf("expected the unknown account to be rejected, got %v", err)
+	}
+	if len(sc.jobs) != 0 {
+		t.Errorf("expected no jobs to be registered, got %d", len(sc.jobs))
+	}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"sykesdev.ca/yls/pkg/stream"
+)
+
+var schemaOutFile string
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "prints the JSON Schema of the streams configuration file",
+	Long:  "prints the JSON Schema of the streams configuration file\n\nThe schema can be used by editors (such as VS Code with the YAML extension) to autocomplete and check streams configuration files. It is generated from the same definitions the validate command uses.",
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := json.MarshalIndent(stream.Schema(), "", "  ")
+		if err != nil {
+			YLSLogger().Fatal("unable to generate the streams configuration schema", zap.Error(err))
+		}
+		b = append(b, '\n')
+
+		if schemaOutFile == "" {
+			os.Stdout.Write(b)
+			return
+		}
+		if err := os.WriteFile(schemaOutFile, b, 0644); err != nil {
+			YLSLogger().Fatal("unable to write the streams configuration schema", zap.String("file", schemaOutFile), zap.Error(err))
+		}
+		YLSLogger().Info("wrote the streams configuration schema", zap.String("file", schemaOutFile))
+	},
+}
+
+func init() {
+	schemaCmd.Flags().StringVar(&schemaOutFile, "out-file", "", "the path of the file to write the schema to instead of stdout")
+
+	rootCmd.AddCommand(schemaCmd)
+}
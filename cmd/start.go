@@ -2,16 +2,12 @@ package cmd
 
 import (
 	"context"
-	"errors"
 	"os"
 	"os/signal"
 	"syscall"
 
-	"github.com/robfig/cron/v3"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
-	"google.golang.org/api/youtube/v3"
-	"gopkg.in/yaml.v3"
 	"sykesdev.ca/yls/pkg/stream"
 )
 
@@ -28,67 +24,92 @@ var startCmd = &cobra.Command{
 		signal.Notify(quit, syscall.SIGINT)
 		ctx := context.Background()
 
-		streamUploader, err := stream.New(&stream.StreamUploaderConfig{
-			Context:     ctx,
-			OauthConfig: oauthConfigFile,
-			Cache:       secretsCache,
-			Scopes:      []string{youtube.YoutubeScope},
-			DryRunMode:  dryRun,
-		})
+		streams, err := getStreamsFromFile()
 		if err != nil {
-			YLSLogger().Fatal("failed to initialize Youtube Stream Uploader Client", zap.Error(err))
+			YLSLogger().Fatal("unable to get streams from input file", zap.String("file", streamConfigFile), zap.Error(err))
 		}
 
-		streams, err := getStreamsFromFile()
+		uploaders, err := newUploaderPool(ctx)
 		if err != nil {
-			YLSLogger().Fatal("unable to get streams from input file", zap.String("file", streamConfigFile), zap.Error(err))
+			YLSLogger().Fatal("failed to initialize Youtube Stream Uploader Client", zap.Error(err))
 		}
 
 		if runNow {
-			for _, s := range streams.Items {
-				streamUploader.Upload(&s)() // this is kindof ugly :(
+			failed := []string{}
+			for i := range streams.Items {
+				streamUploader, err := uploaders.forStream(streams, &streams.Items[i])
+				if err != nil {
+					YLSLogger().Fatal("failed to initialize Youtube Stream Uploader Client", zap.String("streamName", streams.Items[i].Name), zap.Error(err))
+				}
+				if res := streamUploader.Upload(&streams.Items[i]); res.Failed() {
+					failed = append(failed, res.StreamName)
+				}
 			}
 
+			if len(failed) > 0 {
+				YLSLogger().Fatal("completed jobs for all configured streams, but some jobs failed", zap.Int("jobCount", len(streams.Items)), zap.Strings("failedJobs", failed))
+			}
 			YLSLogger().Info("completed jobs for all configured streams", zap.Int("jobCount", len(streams.Items)))
 			return
 		}
 
-		c := cron.New()
-		for _, s := range streams.Items {
-			_, err := c.AddFunc(s.Schedule, streamUploader.Upload(&s))
+		loc, err := streams.Location()
+		if err != nil {
+			YLSLogger().Fatal("failed to load time zone for scheduler", zap.String("timezone", streams.Timezone), zap.Error(err))
+		}
+
+		sc := newScheduler(loc, uploaders)
+		if err := sc.load(streams); err != nil {
+			YLSLogger().Fatal("failed to schedule jobs for streams", zap.Error(err))
+		}
+
+		reload := func() {
+			YLSLogger().Info("reloading streams configuration", zap.String("file", streamConfigFile))
+			streams, err := getStreamsFromFile()
 			if err != nil {
-				YLSLogger().Fatal("failed to create scheduled job for Stream", zap.String("streamName", s.Name), zap.Error(err))
+				YLSLogger().Error("unable to reload streams from input file. keeping the current configuration", zap.String("file", streamConfigFile), zap.Error(err))
+				return
 			}
-			YLSLogger().Info("added new job to scheduler", zap.String("jobName", s.Name), zap.String("jobSchedule", s.Schedule))
+			if err := sc.reload(streams); err != nil {
+				YLSLogger().Error("unable to apply reloaded streams configuration", zap.String("file", streamConfigFile), zap.Error(err))
+			}
+		}
+
+		watcher, err := watchFile(streamConfigFile, reload)
+		if err != nil {
+			YLSLogger().Warn("unable to watch streams configuration file for changes. send SIGHUP to reload instead", zap.String("file", streamConfigFile), zap.Error(err))
+		} else {
+			defer watcher.Close()
 		}
 
-		YLSLogger().Info("starting scheduler")
-		c.Start()
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+
+		YLSLogger().Info("starting scheduler", zap.String("timezone", loc.String()))
+		sc.start()
 
-		sig := <-quit
-		YLSLogger().Info("caught an exit signal. shutting down gracefully", zap.String("signal", sig.String()))
-		stopCtx := c.Stop()
-		<-stopCtx.Done()
+		for {
+			select {
+			case <-hup:
+				reload()
+			case sig := <-quit:
+				YLSLogger().Info("caught an exit signal. shutting down gracefully", zap.String("signal", sig.String()))
+				sc.stop()
+				return
+			}
+		}
 	},
 }
 
+// getStreamsFromFile loads and validates the streams configuration file
 func getStreamsFromFile() (*stream.StreamList, error) {
-	b, err := os.ReadFile(streamConfigFile)
+	streams, err := stream.LoadFile(streamConfigFile)
 	if err != nil {
 		return nil, err
 	}
-
-	var streams stream.StreamList
-	if err := yaml.Unmarshal(b, &streams); err != nil {
-		return nil, err
-	}
 	YLSLogger().Debug("got streams from config file", zap.Any("value", streams.Items))
 
-	if len(streams.Items) == 0 {
-		return nil, errors.New("must specify at least one stream configuration to proceed")
-	}
-
-	return &streams, nil
+	return streams, nil
 }
 
 func init() {
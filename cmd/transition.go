@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/stream"
+)
+
+var transitionCmd = &cobra.Command{
+	Use:   "transition <broadcastID|streamName> <testing|live|complete>",
+	Short: "transitions a broadcast to the testing, live or complete status",
+	Long:  fmt.Sprintf("transitions a broadcast to the testing, live or complete status\n\nThe broadcast is selected by ID or by the name of the stream that created it. For a stream name, completing applies to its active broadcast while testing and going live apply to its next upcoming broadcast.\n\nAllowed transitions: [%s]", strings.Join(stream.TRANSITIONS_ALLOWED, ", ")),
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		target, status := args[0], args[1]
+		if !helper.StringInSlice(status, stream.TRANSITIONS_ALLOWED) {
+			YLSLogger().Fatal("invalid broadcast transition", zap.String("status", status), zap.Strings("allowed", stream.TRANSITIONS_ALLOWED))
+		}
+
+		var streams *stream.StreamList
+		if streamConfigFile != "" {
+			var err error
+			if streams, err = getStreamsFromFile(); err != nil {
+				YLSLogger().Fatal("unable to get streams from input file", zap.String("file", streamConfigFile), zap.Error(err))
+			}
+		}
+
+		account, err := selectAccount(streams, target)
+		if err != nil {
+			YLSLogger().Fatal("unable to select account", zap.String("account", accountName), zap.Error(err))
+		}
+		streamUploader, err := newStreamUploader(context.Background(), account)
+		if err != nil {
+			YLSLogger().Fatal("failed to initialize Youtube Stream Uploader Client", zap.Error(err))
+		}
+
+		// the target is a broadcast ID unless a stream has that name
+		broadcastId := target
+		if (streams != nil && streams.Find(target) != nil) || streamUploader.KnownStream(target) {
+			b, err := streamUploader.ResolveBroadcast(target, status)
+			if err != nil {
+				YLSLogger().Fatal("unable to resolve broadcast for transition", zap.String("streamName", target), zap.Error(err))
+			}
+			if b == nil {
+				YLSLogger().Fatal("no broadcast found for stream", zap.String("streamName", target), zap.String("status", status))
+			}
+			broadcastId = b.Id
+			YLSLogger().Info("resolved broadcast for stream", zap.String("streamName", target), zap.String("broadcastId", broadcastId))
+		}
+
+		if _, err := streamUploader.Transition(broadcastId, status); err != nil {
+			YLSLogger().Fatal("unable to transition broadcast", zap.String("broadcastId", broadcastId), zap.Error(err))
+		}
+	},
+}
+
+func init() {
+	transitionCmd.Flags().StringVarP(&streamConfigFile, "input", "i", "", "the path to the file which specifies configuration for youtube stream schedules. when specified, the account of the stream is used")
+
+	rootCmd.AddCommand(transitionCmd)
+}
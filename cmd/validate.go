@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"sykesdev.ca/yls/pkg/stream"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validates a streams configuration file",
+	Long:  "validates a streams configuration file\n\nThe file is strictly decoded (unknown fields are errors) and every stream is checked for values YouTube does not allow, missing or oversized thumbnails and invalid cron schedules, time zones and templates. Every problem is reported with its line and column. The same validation is run by the start command.",
+	Run: func(cmd *cobra.Command, args []string) {
+		streams, err := getStreamsFromFile()
+		if err != nil {
+			var verrs stream.ValidationErrors
+			if !errors.As(err, &verrs) {
+				YLSLogger().Fatal("unable to read streams from input file", zap.String("file", streamConfigFile), zap.Error(err))
+			}
+			for _, e := range verrs {
+				fmt.Fprintf(os.Stderr, "%s:%s\n", streamConfigFile, e.Error())
+			}
+			YLSLogger().Fatal("streams configuration is invalid", zap.String("file", streamConfigFile), zap.Int("problems", len(verrs)))
+		}
+
+		YLSLogger().Info("streams configuration is valid", zap.String("file", streamConfigFile), zap.Int("streams", len(streams.Items)))
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVarP(&streamConfigFile, "input", "i", "", "the path to the file which specifies configuration for youtube stream schedules")
+
+	validateCmd.MarkFlagRequired("input")
+	rootCmd.AddCommand(validateCmd)
+}
@@ -3,11 +3,16 @@ module sykesdev.ca/yls
 go 1.19
 
 require (
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/robfig/cron/v3 v3.0.0
 	github.com/sogko/go-wordpress v0.0.0-20160322054548-0f4f3dc4231f
 	github.com/spf13/cobra v1.6.1
+	github.com/zalando/go-keyring v0.2.3
 	go.uber.org/zap v1.24.0
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 	golang.org/x/oauth2 v0.5.0
+	golang.org/x/sys v0.8.0
 	google.golang.org/api v0.110.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -15,11 +20,12 @@ require (
 require (
 	github.com/Masterminds/goutils v1.1.1 // indirect
 	github.com/Masterminds/semver v1.5.0 // indirect
+	github.com/alessio/shellescape v1.4.1 // indirect
+	github.com/danieljoos/wincred v1.2.0 // indirect
 	github.com/huandu/xstrings v1.4.0 // indirect
 	github.com/imdario/mergo v0.3.15 // indirect
 	github.com/mitchellh/copystructure v1.2.0 // indirect
 	github.com/mitchellh/reflectwalk v1.0.2 // indirect
-	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
 )
 
 require (
@@ -42,7 +48,6 @@ require (
 	go.uber.org/atomic v1.10.0 // indirect
 	go.uber.org/multierr v1.9.0 // indirect
 	golang.org/x/net v0.7.0 // indirect
-	golang.org/x/sys v0.5.0 // indirect
 	golang.org/x/text v0.7.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/genproto v0.0.0-20230209215440-0dfe4f8abfcc // indirect
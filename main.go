@@ -1,6 +1,11 @@
 package main
 
-import "sykesdev.ca/yls/cmd"
+import (
+	// embed the time zone database so that configured time zones resolve in minimal (scratch) images
+	_ "time/tzdata"
+
+	"sykesdev.ca/yls/cmd"
+)
 
 func main() {
 	cmd.Execute()
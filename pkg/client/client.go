@@ -11,70 +11,99 @@ import (
 
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 	"sykesdev.ca/yls/pkg/logging"
 )
 
-func Get(ctx context.Context, secretsCache string, config *oauth2.Config) *http.Client {
-	tok, err := tokenFromFile(secretsCache)
-	if err != nil {
-		logging.YLSLogger().Warn("unable to get token from secrets file", zap.Error(err))
-		logging.YLSLogger().Warn("trying to get token using device-code flow instead ...")
-		tok = getTokenFromWeb(ctx, config)
-		saveToken(secretsCache, tok)
-	}
+// ErrLoginRequired is returned in non-interactive mode when there is no usable token and the user would have to log in
+var ErrLoginRequired = errors.New("a login is required. run yls login, or provide credentials with --credentials")
 
-	logging.YLSLogger().Debug("successfully obtained access and refresh tokens for oauth client", zap.String("cacheLocation", secretsCache))
-	return config.Client(ctx, tok)
+// Options configures how the HTTP client obtains its token
+type Options struct {
+	Store     TokenStore
+	LoginMode string
+	// NonInteractive returns ErrLoginRequired instead of asking the user to log in
+	NonInteractive bool
 }
 
-func getTokenFromWeb(ctx context.Context, config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to: \n%s\n", authURL)
-	fmt.Println("Enter Authorization Code: ")
+// Get creates an HTTP client authorized by the token in the store. When there is no usable token, the user is asked to
+// log in, unless the client is non-interactive
+func Get(ctx context.Context, config *oauth2.Config, opts *Options) (*http.Client, error) {
+	store := opts.Store
+	tok, err := tokenFromStore(store)
+	if err == nil {
+		// make sure an expired token can still be refreshed, rather than failing on the first API call
+		ts := newPersistingTokenSource(ctx, config, store, tok)
+		_, err = ts.Token()
+		if err == nil {
+			logging.YLSLogger().Debug("successfully obtained access and refresh tokens for oauth client", zap.Stringer("store", store))
+			return oauth2.NewClient(ctx, ts), nil
+		}
+		// only a refresh token rejected by Google requires a new login. other errors (such as the network being down)
+		// would fail the same way after logging in
+		var retrieveErr *oauth2.RetrieveError
+		if !errors.As(err, &retrieveErr) {
+			return nil, fmt.Errorf("unable to refresh token from %s. %w", store, err)
+		}
+	}
 
-	var code string
-	if _, err := fmt.Scan(&code); err != nil {
-		logging.YLSLogger().Fatal("unable to read authorization code from stdin", zap.Error(err))
+	if opts.NonInteractive {
+		return nil, fmt.Errorf("unable to get a usable token from %s. %s. %w", store, err, ErrLoginRequired)
 	}
 
-	tok, err := config.Exchange(ctx, code)
+	logging.YLSLogger().Warn("unable to get token from token store", zap.Stringer("store", store), zap.Error(err))
+	logging.YLSLogger().Warn("trying to get token by logging in instead ...", zap.String("loginMode", opts.LoginMode))
+	tok, err = Login(ctx, config, opts.LoginMode)
 	if err != nil {
-		logging.YLSLogger().Fatal("unable to retrieve token from web", zap.Error(err))
+		return nil, fmt.Errorf("unable to retrieve token from web. %w", err)
 	}
-	return tok
+	if err := store.Save(tok); err != nil {
+		logging.YLSLogger().Warn("unable to cache oauth token", zap.Stringer("store", store), zap.Error(err))
+	}
+
+	return oauth2.NewClient(ctx, newPersistingTokenSource(ctx, config, store, tok)), nil
 }
 
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
+// FromCredentials creates an HTTP client from a credentials file in the format of GOOGLE_APPLICATION_CREDENTIALS, such
+// as the authorized_user credentials (client ID, client secret and refresh token) written by gcloud. It never prompts
+func FromCredentials(ctx context.Context, path string, scopes ...string) (*http.Client, error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("unable to read credentials file. %w", err)
 	}
-	defer f.Close()
 
-	t := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(t)
+	creds, err := google.CredentialsFromJSON(ctx, b, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse credentials file %s. %w", path, err)
+	}
 
-	// only triggers if scopes setup by application owner do not include 'offline_access' and therefore do not include
-	// a refresh token.
-	if time.Now().After(t.Expiry) && t.RefreshToken == "" {
-		logging.YLSLogger().Warn("youtube authentication tokens have expired",
-			zap.String("token", t.AccessToken),
-			zap.Time("expired", t.Expiry),
-			zap.String("rt", t.RefreshToken),
-		)
-		return nil, errors.New("token expired")
+	var meta struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(b, &meta) == nil && meta.Type == "service_account" {
+		logging.YLSLogger().Warn("service accounts cannot own a YouTube channel. use authorized_user credentials of the channel owner unless the account has been granted access", zap.String("credentials", path))
+	}
+
+	if _, err := creds.TokenSource.Token(); err != nil {
+		return nil, fmt.Errorf("unable to get a token using the credentials file %s. %w", path, err)
 	}
 
-	return t, err
+	logging.YLSLogger().Debug("successfully obtained access token from credentials file", zap.String("credentials", path))
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
 }
 
-func saveToken(file string, token *oauth2.Token) {
-	logging.YLSLogger().Debug("saving credentials to secrets cache", zap.String("cache_location", file))
-	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+func tokenFromStore(store TokenStore) (*oauth2.Token, error) {
+	t, err := store.Load()
 	if err != nil {
-		logging.YLSLogger().Warn("unable to cache oauth token", zap.Error(err))
+		return nil, err
+	}
+
+	// only triggers if scopes setup by application owner do not include 'offline_access' and therefore do not include
+	// a refresh token.
+	if time.Now().After(t.Expiry) && t.RefreshToken == "" {
+		logging.YLSLogger().Warn("youtube authentication tokens have expired", zap.Time("expired", t.Expiry))
+		return nil, errors.New("token expired")
 	}
-	defer f.Close()
 
-	json.NewEncoder(f).Encode(token)
+	return t, nil
 }
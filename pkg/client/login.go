@@ -0,0 +1,301 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+const (
+	// LOGIN_MODE_BROWSER redirects the browser to a short-lived listener on the loopback interface. The redirected URL
+	// can also be pasted into the terminal, for when the browser runs on another machine
+	LOGIN_MODE_BROWSER = "browser"
+	// LOGIN_MODE_DEVICE uses the OAuth2.0 device authorization grant, where a code is entered on another device
+	LOGIN_MODE_DEVICE = "device"
+)
+
+var LOGIN_MODES_ALLOWED = []string{LOGIN_MODE_BROWSER, LOGIN_MODE_DEVICE}
+
+const (
+	LOGIN_TIMEOUT          = 5 * time.Minute
+	GOOGLE_DEVICE_AUTH_URL = "https://oauth2.googleapis.com/device/code"
+
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// Login obtains a new token for the account by asking the user to authorize YLS
+func Login(ctx context.Context, config *oauth2.Config, mode string) (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, LOGIN_TIMEOUT)
+	defer cancel()
+
+	switch mode {
+	case LOGIN_MODE_BROWSER, "":
+		return loginWithBrowser(ctx, config)
+	case LOGIN_MODE_DEVICE:
+		return loginWithDeviceCode(ctx, config)
+	}
+	return nil, fmt.Errorf("invalid login mode %q. must be one of [%s]", mode, strings.Join(LOGIN_MODES_ALLOWED, ", "))
+}
+
+// loginWithBrowser runs the authorization code flow with PKCE, using a loopback redirect URI
+func loginWithBrowser(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen for the oauth redirect. %w", err)
+	}
+	defer l.Close()
+
+	cfg := *config
+	cfg.RedirectURL = fmt.Sprintf("http://%s/", l.Addr().String())
+
+	state, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL := cfg.AuthCodeURL(state,
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("prompt", "consent"),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	srv := &http.Server{Handler: redirectHandler(state, codes, errs)}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	// when the browser runs on another machine, the redirect fails there and its URL can be pasted here instead. a read
+	// from stdin cannot be cancelled, so once the login is done the reader exits on the next line or at EOF
+	lines := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "Open the following URL in a browser to authorize YLS:\n\n%s\n\n", authURL)
+	fmt.Fprintln(os.Stderr, "If the browser runs on another machine, paste the URL it is redirected to (even if the page fails to load) here:")
+	if err := openBrowser(authURL); err != nil {
+		logging.YLSLogger().Debug("unable to open a browser", zap.Error(err))
+	}
+
+	for {
+		select {
+		case code := <-codes:
+			return cfg.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+		case err := <-errs:
+			return nil, err
+		case line := <-lines:
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			u, err := url.Parse(line)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "unable to parse the pasted URL. %s\n", err)
+				continue
+			}
+			code, err := codeFromRedirect(u, state)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				continue
+			}
+			return cfg.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for authorization. %w", ctx.Err())
+		}
+	}
+}
+
+// redirectHandler answers the redirect of the login attempt with the given state, sending its code to codes. The login
+// fails only when the redirect reports an error, such as the user denying access
+func redirectHandler(state string, codes chan<- string, errs chan<- error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// browsers also request the favicon, and anything on this machine can reach the listener. only the redirect of
+		// this login attempt is answered, so that stray requests cannot abort the login
+		if r.URL.Path != "/" || r.URL.Query().Get("state") != state {
+			http.NotFound(w, r)
+			return
+		}
+
+		code, err := codeFromRedirect(r.URL, state)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("YLS login failed: %s", err), http.StatusBadRequest)
+			if r.URL.Query().Get("error") != "" {
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+			return
+		}
+		fmt.Fprintln(w, "YLS login succeeded. You can close this window.")
+		select {
+		case codes <- code:
+		default:
+		}
+	})
+}
+
+// codeFromRedirect extracts the authorization code from the URL the browser was redirected to
+func codeFromRedirect(u *url.URL, state string) (string, error) {
+	q := u.Query()
+	if e := q.Get("error"); e != "" {
+		return "", fmt.Errorf("authorization was denied. %s", e)
+	}
+	if q.Get("state") != state {
+		return "", errors.New("the state of the redirect does not match. make sure the URL is from the latest login attempt")
+	}
+	code := q.Get("code")
+	if code == "" {
+		return "", errors.New("the redirect does not contain an authorization code")
+	}
+	return code, nil
+}
+
+type deviceCodeResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURL string `json:"verification_url"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+type deviceTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+}
+
+// loginWithDeviceCode runs the OAuth2.0 device authorization grant. Google only allows it for OAuth clients of the
+// "TVs and Limited Input devices" type
+func loginWithDeviceCode(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	var dc deviceCodeResponse
+	err := postForm(ctx, GOOGLE_DEVICE_AUTH_URL, url.Values{
+		"client_id": {config.ClientID},
+		"scope":     {strings.Join(config.Scopes, " ")},
+	}, &dc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request a device code. %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Go to %s on any device and enter the code: %s\n", dc.VerificationURL, dc.UserCode)
+
+	interval := time.Duration(dc.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for authorization. %w", ctx.Err())
+		case <-time.After(interval):
+		}
+
+		var tr deviceTokenResponse
+		err := postForm(ctx, config.Endpoint.TokenURL, url.Values{
+			"client_id":     {config.ClientID},
+			"client_secret": {config.ClientSecret},
+			"device_code":   {dc.DeviceCode},
+			"grant_type":    {deviceCodeGrantType},
+		}, &tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to poll for the device token. %w", err)
+		}
+
+		switch tr.Error {
+		case "":
+			return &oauth2.Token{
+				AccessToken:  tr.AccessToken,
+				RefreshToken: tr.RefreshToken,
+				TokenType:    tr.TokenType,
+				Expiry:       time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second),
+			}, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return nil, errors.New("authorization was denied")
+		case "expired_token":
+			return nil, errors.New("the device code expired before it was entered")
+		default:
+			return nil, fmt.Errorf("device authorization failed. %s", tr.Error)
+		}
+	}
+}
+
+// postForm posts a form and decodes the JSON response. OAuth2.0 errors are decoded rather than returned, since the
+// device flow relies on them
+func postForm(ctx context.Context, endpoint string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("unexpected response (%s). %w", resp.Status, err)
+	}
+	return nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// openBrowser opens a URL in the default browser, where there is one
+func openBrowser(u string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", u).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", u).Start()
+	}
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return errors.New("no display is available")
+	}
+	return exec.Command("xdg-open", u).Start()
+}
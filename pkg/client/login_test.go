@@ -0,0 +1,57 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		status int
+		code   string
+		fails  bool
+	}{
+		{name: "favicon", target: "/favicon.ico", status: http.StatusNotFound},
+		{name: "other path", target: "/callback?state=login-state&code=abc", status: http.StatusNotFound},
+		{name: "stale state", target: "/?state=other-state&code=abc", status: http.StatusNotFound},
+		{name: "stray error", target: "/?error=access_denied", status: http.StatusNotFound},
+		{name: "missing code", target: "/?state=login-state", status: http.StatusBadRequest},
+		{name: "denied", target: "/?state=login-state&error=access_denied", status: http.StatusBadRequest, fails: true},
+		{name: "authorized", target: "/?state=login-state&code=abc", status: http.StatusOK, code: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes := make(chan string, 1)
+			errs := make(chan error, 1)
+			w := httptest.NewRecorder()
+			redirectHandler("login-state", codes, errs).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			select {
+			case code := <-codes:
+				if code != tt.code {
+					t.Errorf("expected code %q, got %q", tt.code, code)
+				}
+			default:
+				if tt.code != "" {
+					t.Errorf("expected code %q, got none", tt.code)
+				}
+			}
+			select {
+			case err := <-errs:
+				if !tt.fails {
+					t.Errorf("expected the login to continue, got %s", err)
+				}
+			default:
+				if tt.fails {
+					t.Error("expected the login to fail")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+const (
+	TOKEN_STORE_FILE      = "file"
+	TOKEN_STORE_ENCRYPTED = "encrypted"
+	TOKEN_STORE_KEYRING   = "keyring"
+)
+
+var TOKEN_STORES_ALLOWED = []string{TOKEN_STORE_FILE, TOKEN_STORE_ENCRYPTED, TOKEN_STORE_KEYRING}
+
+const (
+	// TOKEN_PASSPHRASE_ENV is the environment variable holding the passphrase of an encrypted token cache
+	TOKEN_PASSPHRASE_ENV = "YLS_TOKEN_PASSPHRASE"
+	// KEYRING_SERVICE is the service the token is stored under in the OS keyring
+	KEYRING_SERVICE = "yls"
+	// KEYRING_DEFAULT_USER is the keyring account the token is stored under unless another is given
+	KEYRING_DEFAULT_USER = "default"
+
+	encryptedTokenVersion = 1
+	encryptedTokenKDF     = "scrypt"
+	scryptN               = 1 << 15
+	scryptR               = 8
+	scryptP               = 1
+)
+
+// ErrTokenNotFound is returned by a TokenStore which does not hold a token yet
+var ErrTokenNotFound = errors.New("no token has been stored")
+
+// TokenStore persists the OAuth2.0 token of the authenticated account between runs
+type TokenStore interface {
+	Load() (*oauth2.Token, error)
+	Save(token *oauth2.Token) error
+	// String describes where the token is stored, for logging
+	String() string
+}
+
+// NewTokenStore creates the TokenStore of the given kind. For files, location is the path of the cache. For the
+// keyring, location is the account the token is stored under
+func NewTokenStore(kind, location string, passphrase []byte) (TokenStore, error) {
+	switch kind {
+	case TOKEN_STORE_FILE, "":
+		return &FileStore{Path: location}, nil
+	case TOKEN_STORE_ENCRYPTED:
+		if len(passphrase) == 0 {
+			return nil, fmt.Errorf("a passphrase is required for an encrypted token cache. set %s or specify a key file", TOKEN_PASSPHRASE_ENV)
+		}
+		return &EncryptedFileStore{Path: location, Passphrase: passphrase}, nil
+	case TOKEN_STORE_KEYRING:
+		if location == "" {
+			location = KEYRING_DEFAULT_USER
+		}
+		return &KeyringStore{Service: KEYRING_SERVICE, User: location}, nil
+	}
+	return nil, fmt.Errorf("invalid token store %q", kind)
+}
+
+// FileStore stores the token as plain JSON in a file only readable by its owner
+type FileStore struct {
+	Path string
+}
+
+func (s *FileStore) Load() (*oauth2.Token, error) {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &oauth2.Token{}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("unable to decode token from %s. %w", s.Path, err)
+	}
+	return t, nil
+}
+
+func (s *FileStore) Save(token *oauth2.Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, b)
+}
+
+func (s *FileStore) String() string {
+	return "file:" + s.Path
+}
+
+// EncryptedFileStore stores the token in a file encrypted with NaCl secretbox, using a key derived from a passphrase
+// with scrypt
+type EncryptedFileStore struct {
+	Path       string
+	Passphrase []byte
+}
+
+type encryptedToken struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func (s *EncryptedFileStore) Load() (*oauth2.Token, error) {
+	b, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var et encryptedToken
+	if err := json.Unmarshal(b, &et); err != nil {
+		return nil, fmt.Errorf("%s is not an encrypted token cache", s.Path)
+	}
+	if et.Version == 0 {
+		return s.migrate(b)
+	}
+	if et.Version != encryptedTokenVersion || et.KDF != encryptedTokenKDF || len(et.Nonce) != 24 {
+		return nil, fmt.Errorf("unsupported encrypted token cache format (version %d, kdf %s)", et.Version, et.KDF)
+	}
+
+	key, err := deriveKey(s.Passphrase, et.Salt)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], et.Nonce)
+	plain, ok := secretbox.Open(nil, et.Data, &nonce, key)
+	if !ok {
+		return nil, errors.New("unable to decrypt token cache. the passphrase is incorrect or the file has been tampered with")
+	}
+
+	t := &oauth2.Token{}
+	if err := json.Unmarshal(plain, t); err != nil {
+		return nil, fmt.Errorf("unable to decode decrypted token. %w", err)
+	}
+	return t, nil
+}
+
+func (s *EncryptedFileStore) Save(token *oauth2.Token) error {
+	plain, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	et := encryptedToken{
+		Version: encryptedTokenVersion,
+		KDF:     encryptedTokenKDF,
+		Salt:    make([]byte, 16),
+		Nonce:   make([]byte, 24),
+	}
+	if _, err := rand.Read(et.Salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(et.Nonce); err != nil {
+		return err
+	}
+	key, err := deriveKey(s.Passphrase, et.Salt)
+	if err != nil {
+		return err
+	}
+	var nonce [24]byte
+	copy(nonce[:], et.Nonce)
+	et.Data = secretbox.Seal(nil, plain, &nonce, key)
+
+	b, err := json.Marshal(et)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Path, b)
+}
+
+// migrate encrypts a plain token cache in place, so that switching to an encrypted cache does not require a new login
+func (s *EncryptedFileStore) migrate(b []byte) (*oauth2.Token, error) {
+	t := &oauth2.Token{}
+	if err := json.Unmarshal(b, t); err != nil || (t.AccessToken == "" && t.RefreshToken == "") {
+		return nil, fmt.Errorf("%s is not an encrypted token cache", s.Path)
+	}
+
+	logging.YLSLogger().Warn("found a plain token cache. encrypting it in place", zap.String("cacheLocation", s.Path))
+	if err := s.Save(t); err != nil {
+		return nil, fmt.Errorf("unable to encrypt plain token cache. %w", err)
+	}
+	return t, nil
+}
+
+func (s *EncryptedFileStore) String() string {
+	return "encrypted:" + s.Path
+}
+
+func deriveKey(passphrase, salt []byte) (*[32]byte, error) {
+	k, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unable to derive token cache key. %w", err)
+	}
+	var key [32]byte
+	copy(key[:], k)
+	return &key, nil
+}
+
+// KeyringStore stores the token in the OS keyring (the Secret Service over D-Bus on Linux, the Keychain on macOS and
+// the Credential Manager on Windows)
+type KeyringStore struct {
+	Service string
+	User    string
+}
+
+func (s *KeyringStore) Load() (*oauth2.Token, error) {
+	secret, err := keyring.Get(s.Service, s.User)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token from keyring. %w", err)
+	}
+
+	t := &oauth2.Token{}
+	if err := json.Unmarshal([]byte(secret), t); err != nil {
+		return nil, fmt.Errorf("unable to decode token from keyring. %w", err)
+	}
+	return t, nil
+}
+
+func (s *KeyringStore) Save(token *oauth2.Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(s.Service, s.User, string(b)); err != nil {
+		return fmt.Errorf("unable to write token to keyring. %w", err)
+	}
+	return nil
+}
+
+func (s *KeyringStore) String() string {
+	return fmt.Sprintf("keyring:%s/%s", s.Service, s.User)
+}
+
+// writeFileAtomic replaces a file with data readable only by its owner, so that a crash never leaves a partial token
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
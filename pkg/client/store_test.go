@@ -0,0 +1,373 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+	"golang.org/x/oauth2"
+)
+
+func testToken() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  "access-token",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh-token",
+		Expiry:       time.Date(2023, 3, 5, 14, 0, 0, 0, time.UTC),
+	}
+}
+
+func assertToken(t *testing.T, got *oauth2.Token) {
+	t.Helper()
+
+	want := testToken()
+	if got.AccessToken != want.AccessToken || got.RefreshToken != want.RefreshToken || !got.Expiry.Equal(want.Expiry) {
+		t.Errorf("expected token %+v, got %+v", want, got)
+	}
+}
+
+func TestEncryptedFileStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	s := &EncryptedFileStore{Path: path, Passphrase: []byte("correct horse")}
+
+	if _, err := s.Load(); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("expected ErrTokenNotFound before saving, got %v", err)
+	}
+	if err := s.Save(testToken()); err != nil {
+		t.Fatalf("failed to save token. %s", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("refresh-token")) {
+		t.Errorf("expected the token cache to be encrypted, got %s", b)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("expected the token cache to be readable only by its owner, got %s", info.Mode().Perm())
+	}
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("failed to load token. %s", err)
+	}
+	assertToken(t, got)
+}
+
+func TestEncryptedFileStoreWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := (&EncryptedFileStore{Path: path, Passphrase: []byte("correct horse")}).Save(testToken()); err != nil {
+		t.Fatalf("failed to save token. %s", err)
+	}
+
+	_, err := (&EncryptedFileStore{Path: path, Passphrase: []byte("battery staple")}).Load()
+	if err == nil {
+		t.Fatal("expected an error loading the token with the wrong passphrase")
+	}
+	if errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected a decryption error rather than a missing token, got %s", err)
+	}
+}
+
+func TestEncryptedFileStoreMigratesPlainCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := (&FileStore{Path: path}).Save(testToken()); err != nil {
+		t.Fatalf("failed to save plain token. %s", err)
+	}
+
+	s := &EncryptedFileStore{Path: path, Passphrase: []byte("correct horse")}
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("failed to load plain token. %s", err)
+	}
+	assertToken(t, got)
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var et encryptedToken
+	if err := json.Unmarshal(b, &et); err != nil || et.Version != encryptedTokenVersion {
+		t.Fatalf("expected the plain cache to be encrypted in place, got %s", b)
+	}
+
+	got, err = s.Load()
+	if err != nil {
+		t.Fatalf("failed to load migrated token. %s", err)
+	}
+	assertToken(t, got)
+}
+
+func TestEncryptedFileStoreRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(path, []byte(`{"installed": {}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (&EncryptedFileStore{Path: path, Passphrase: []byte("correct horse")}).Load(); err == nil {
+		t.Error("expected an error loading a file which is not a token cache")
+	}
+}
+
+const (
+	secretServiceName           = "org.freedesktop.secrets"
+	secretServicePath           = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretServiceInterface      = "org.freedesktop.Secret.Service"
+	secretServiceCollectionPath = dbus.ObjectPath("/org/freedesktop/secrets/collection/login")
+	secretServiceCollection     = "org.freedesktop.Secret.Collection"
+	secretServiceItem           = "org.freedesktop.Secret.Item"
+	secretServiceSession        = "org.freedesktop.Secret.Session"
+)
+
+// secretServiceSecret is the (oayays) secret struct of the Secret Service API
+type secretServiceSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// secretService is an in-memory stand-in for the Secret Service API with a single, always unlocked, login collection.
+// Only the plain transfer algorithm is supported
+type secretService struct {
+	conn  *dbus.Conn
+	mu    sync.Mutex
+	next  int
+	items map[dbus.ObjectPath]*secretServiceItemObject
+}
+
+type secretServiceCollectionObject struct{ svc *secretService }
+
+type secretServiceItemObject struct {
+	svc        *secretService
+	path       dbus.ObjectPath
+	attributes map[string]string
+	secret     []byte
+}
+
+type secretServiceSessionObject struct{}
+
+// startSecretService starts a private session bus with a Secret Service stand-in on it, and points the session bus of
+// the test at it. The test is skipped when no dbus-daemon is available
+func startSecretService(t *testing.T) *secretService {
+	t.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not available")
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "session.conf")
+	if err := os.WriteFile(config, []byte(`<busconfig>
+  <type>session</type>
+  <listen>unix:dir=`+dir+`</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start dbus-daemon. %s", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Skipf("unable to read the address of dbus-daemon. %s", err)
+	}
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(address))
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		t.Fatalf("failed to connect to the private session bus. %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	svc := &secretService{conn: conn, items: map[dbus.ObjectPath]*secretServiceItemObject{}}
+	if err := conn.Export(svc, secretServicePath, secretServiceInterface); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := prop.Export(conn, secretServicePath, prop.Map{
+		secretServiceInterface: {"Collections": {Value: []dbus.ObjectPath{secretServiceCollectionPath}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Export(&secretServiceCollectionObject{svc}, secretServiceCollectionPath, secretServiceCollection); err != nil {
+		t.Fatal(err)
+	}
+	if reply, err := conn.RequestName(secretServiceName, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("failed to own %s. %v", secretServiceName, err)
+	}
+	return svc
+}
+
+func (s *secretService) OpenSession(algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if algorithm != "plain" {
+		return dbus.MakeVariant(""), "/", dbus.NewError("org.freedesktop.DBus.Error.NotSupported", []interface{}{algorithm})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/secrets/session/%d", s.next))
+	if err := s.conn.Export(secretServiceSessionObject{}, path, secretServiceSession); err != nil {
+		return dbus.MakeVariant(""), "/", dbus.MakeFailedError(err)
+	}
+	return dbus.MakeVariant(""), path, nil
+}
+
+func (s *secretService) Unlock(objects []dbus.ObjectPath) ([]dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	return objects, "/", nil
+}
+
+// Items returns the attributes and secrets of all the items stored in the stand-in
+func (s *secretService) Items() []*secretServiceItemObject {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []*secretServiceItemObject
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	return items
+}
+
+func (c *secretServiceCollectionObject) CreateItem(properties map[string]dbus.Variant, secret secretServiceSecret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	attributes, _ := properties[secretServiceItem+".Attributes"].Value().(map[string]string)
+
+	c.svc.mu.Lock()
+	defer c.svc.mu.Unlock()
+	if replace {
+		for _, item := range c.svc.items {
+			if reflect.DeepEqual(item.attributes, attributes) {
+				item.secret = secret.Value
+				return item.path, "/", nil
+			}
+		}
+	}
+
+	c.svc.next++
+	item := &secretServiceItemObject{
+		svc:        c.svc,
+		path:       dbus.ObjectPath(fmt.Sprintf("%s/%d", secretServiceCollectionPath, c.svc.next)),
+		attributes: attributes,
+		secret:     secret.Value,
+	}
+	if err := c.svc.conn.Export(item, item.path, secretServiceItem); err != nil {
+		return "/", "/", dbus.MakeFailedError(err)
+	}
+	c.svc.items[item.path] = item
+	return item.path, "/", nil
+}
+
+func (c *secretServiceCollectionObject) SearchItems(attributes map[string]string) ([]dbus.ObjectPath, *dbus.Error) {
+	c.svc.mu.Lock()
+	defer c.svc.mu.Unlock()
+
+	results := []dbus.ObjectPath{}
+	for path, item := range c.svc.items {
+		matches := true
+		for k, v := range attributes {
+			matches = matches && item.attributes[k] == v
+		}
+		if matches {
+			results = append(results, path)
+		}
+	}
+	return results, nil
+}
+
+func (i *secretServiceItemObject) GetSecret(session dbus.ObjectPath) (secretServiceSecret, *dbus.Error) {
+	i.svc.mu.Lock()
+	defer i.svc.mu.Unlock()
+	return secretServiceSecret{Session: session, Parameters: []byte{}, Value: i.secret, ContentType: "text/plain"}, nil
+}
+
+func (i *secretServiceItemObject) Delete() (dbus.ObjectPath, *dbus.Error) {
+	i.svc.mu.Lock()
+	defer i.svc.mu.Unlock()
+	delete(i.svc.items, i.path)
+	i.svc.conn.Export(nil, i.path, secretServiceItem)
+	return "/", nil
+}
+
+func (secretServiceSessionObject) Close() *dbus.Error {
+	return nil
+}
+
+func TestKeyringStoreRoundTrip(t *testing.T) {
+	svc := startSecretService(t)
+
+	store, err := NewTokenStore(TOKEN_STORE_KEYRING, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create keyring store. %s", err)
+	}
+	if s := store.String(); s != "keyring:yls/default" {
+		t.Errorf("expected the default keyring account, got %s", s)
+	}
+
+	if _, err := store.Load(); !errors.Is(err, ErrTokenNotFound) {
+		t.Fatalf("expected ErrTokenNotFound before saving, got %v", err)
+	}
+	if err := store.Save(testToken()); err != nil {
+		t.Fatalf("failed to save token. %s", err)
+	}
+	updated := testToken()
+	updated.AccessToken = "refreshed-access-token"
+	if err := store.Save(updated); err != nil {
+		t.Fatalf("failed to save refreshed token. %s", err)
+	}
+
+	items := svc.Items()
+	if len(items) != 1 {
+		t.Fatalf("expected 1 item in the secret service, got %d", len(items))
+	}
+	if want := map[string]string{"service": KEYRING_SERVICE, "username": KEYRING_DEFAULT_USER}; !reflect.DeepEqual(items[0].attributes, want) {
+		t.Errorf("expected the token stored with attributes %v, got %v", want, items[0].attributes)
+	}
+
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("failed to load token. %s", err)
+	}
+	if got.AccessToken != updated.AccessToken {
+		t.Errorf("expected the refreshed token to replace the stored token, got %+v", got)
+	}
+
+	other := &KeyringStore{Service: KEYRING_SERVICE, User: "other"}
+	if _, err := other.Load(); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected tokens to be stored per account, got %v", err)
+	}
+}
+
+func TestNewTokenStoreRequiresPassphrase(t *testing.T) {
+	if _, err := NewTokenStore(TOKEN_STORE_ENCRYPTED, "token.json", nil); err == nil {
+		t.Error("expected an error creating an encrypted store without a passphrase")
+	}
+}
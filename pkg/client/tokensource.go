@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+// persistingTokenSource refreshes the access token when it expires and saves every refreshed token to the TokenStore,
+// so that the cache never holds a stale token and other processes using the same cache share a valid token
+type persistingTokenSource struct {
+	mu    sync.Mutex
+	store TokenStore
+	token *oauth2.Token
+	// refresher returns the source which refreshes the given token
+	refresher func(token *oauth2.Token) oauth2.TokenSource
+}
+
+func newPersistingTokenSource(ctx context.Context, config *oauth2.Config, store TokenStore, token *oauth2.Token) oauth2.TokenSource {
+	return &persistingTokenSource{
+		store: store,
+		token: token,
+		refresher: func(token *oauth2.Token) oauth2.TokenSource {
+			return config.TokenSource(ctx, token)
+		},
+	}
+}
+
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	// another process sharing the cache may have refreshed (and rotated) the token already
+	if stored, err := s.store.Load(); err == nil && stored.Valid() {
+		logging.YLSLogger().Debug("using token refreshed by another process", zap.Stringer("store", s.store), zap.Time("expiry", stored.Expiry))
+		s.token = stored
+		return stored, nil
+	}
+
+	tok, err := s.refresher(s.token).Token()
+	if err != nil {
+		return nil, err
+	}
+	s.token = tok
+
+	if err := s.store.Save(tok); err != nil {
+		logging.YLSLogger().Warn("unable to save refreshed oauth token", zap.Stringer("store", s.store), zap.Error(err))
+	} else {
+		logging.YLSLogger().Debug("saved refreshed oauth token", zap.Stringer("store", s.store), zap.Time("expiry", tok.Expiry))
+	}
+	return tok, nil
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// fakeTokenSource counts the refreshes of a token, returning the same refreshed token each time
+type fakeTokenSource struct {
+	refreshed *oauth2.Token
+	err       error
+	calls     int
+	given     *oauth2.Token
+}
+
+func (f *fakeTokenSource) refresher(token *oauth2.Token) oauth2.TokenSource {
+	f.given = token
+	return f
+}
+
+func (f *fakeTokenSource) Token() (*oauth2.Token, error) {
+	f.calls++
+	return f.refreshed, f.err
+}
+
+func expiredToken() *oauth2.Token {
+	return &oauth2.Token{AccessToken: "expired", RefreshToken: "refresh-token", Expiry: time.Now().Add(-time.Hour)}
+}
+
+func validToken(access string) *oauth2.Token {
+	return &oauth2.Token{AccessToken: access, RefreshToken: "refresh-token", Expiry: time.Now().Add(time.Hour)}
+}
+
+func newTestTokenSource(t *testing.T, token *oauth2.Token, fake *fakeTokenSource) (*persistingTokenSource, *FileStore) {
+	t.Helper()
+
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "token.json")}
+	return &persistingTokenSource{store: store, token: token, refresher: fake.refresher}, store
+}
+
+func TestPersistingTokenSourceSavesRefreshedToken(t *testing.T) {
+	fake := &fakeTokenSource{refreshed: validToken("refreshed")}
+	ts, store := newTestTokenSource(t, expiredToken(), fake)
+
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("expected the token to be refreshed, got %s", err)
+	}
+	if tok.AccessToken != "refreshed" || fake.calls != 1 {
+		t.Fatalf("expected 1 refresh returning the refreshed token, got %d returning %q", fake.calls, tok.AccessToken)
+	}
+	if fake.given.AccessToken != "expired" {
+		t.Errorf("expected the expired token to be refreshed, got %q", fake.given.AccessToken)
+	}
+
+	stored, err := store.Load()
+	if err != nil {
+		t.Fatalf("expected the refreshed token to be saved, got %s", err)
+	}
+	if stored.AccessToken != "refreshed" {
+		t.Errorf("expected the refreshed token in the store, got %q", stored.AccessToken)
+	}
+
+	if _, err := ts.Token(); err != nil || fake.calls != 1 {
+		t.Errorf("expected the refreshed token to be reused, got %d refreshes and error %v", fake.calls, err)
+	}
+}
+
+func TestPersistingTokenSourceReusesStoredToken(t *testing.T) {
+	fake := &fakeTokenSource{refreshed: validToken("refreshed")}
+	ts, store := newTestTokenSource(t, expiredToken(), fake)
+	// another process sharing the store has refreshed the token already
+	if err := store.Save(validToken("shared")); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatalf("expected the stored token, got %s", err)
+	}
+	if tok.AccessToken != "shared" {
+		t.Errorf("expected the token refreshed by the other process, got %q", tok.AccessToken)
+	}
+	if fake.calls != 0 {
+		t.Errorf("expected no refresh, got %d", fake.calls)
+	}
+}
+
+func TestPersistingTokenSourceRefreshesExpiredStoredToken(t *testing.T) {
+	fake := &fakeTokenSource{refreshed: validToken("refreshed")}
+	ts, store := newTestTokenSource(t, expiredToken(), fake)
+	if err := store.Save(expiredToken()); err != nil {
+		t.Fatal(err)
+	}
+
+	if tok, err := ts.Token(); err != nil || tok.AccessToken != "refreshed" {
+		t.Fatalf("expected the token to be refreshed, got %v and error %v", tok, err)
+	}
+	if fake.calls != 1 {
+		t.Errorf("expected 1 refresh, got %d", fake.calls)
+	}
+}
+
+func TestPersistingTokenSourceRefreshFailure(t *testing.T) {
+	failure := errors.New("invalid_grant")
+	fake := &fakeTokenSource{err: failure}
+	ts, store := newTestTokenSource(t, expiredToken(), fake)
+
+	if _, err := ts.Token(); !errors.Is(err, failure) {
+		t.Fatalf("expected the refresh error, got %v", err)
+	}
+	if _, err := store.Load(); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("expected nothing to be saved, got %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+// Package helper holds the small helpers shared by the YLS commands and packages
+package helper
+
+// StringInSlice reports whether s is one of ss
+func StringInSlice(s string, ss []string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultValue returns val, or def when val is the nil value of its type
+func DefaultValue[T comparable](val, def, nilValue T) T {
+	if val != nilValue {
+		return val
+	}
+	return def
+}
@@ -0,0 +1,272 @@
+package pub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/youtube/v3"
+	"gopkg.in/yaml.v3"
+
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+const PUBLISHER_DISCORD string = "discord"
+
+const DISCORD_DEFAULT_CONTENT = "{{ .Broadcast.Snippet.Title }} goes live <t:{{ .ScheduledStart.Unix }}:F>"
+
+func init() {
+	Register(Registration{
+		Name:        PUBLISHER_DISCORD,
+		Description: "Announce each broadcast in a Discord channel using a webhook",
+		Factory: func(n *yaml.Node) (Config, error) {
+			cfg := &DiscordConfig{}
+			if err := n.Decode(cfg); err != nil {
+				return nil, err
+			}
+			return cfg, nil
+		},
+		ConfigType: reflect.TypeOf(DiscordConfig{}),
+	})
+}
+
+type DiscordConfig struct {
+	WebhookURL string             `yaml:"webhookUrl" json:"-"`
+	Username   string             `yaml:"username,omitempty"`
+	AvatarURL  string             `yaml:"avatarUrl,omitempty"`
+	Content    string             `yaml:"content,omitempty"`
+	Embed      DiscordEmbedConfig `yaml:"embed,omitempty"`
+}
+
+type DiscordEmbedConfig struct {
+	Disabled    bool   `yaml:"disabled,omitempty"`
+	Title       string `yaml:"title,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	Color       int    `yaml:"color,omitempty"`
+}
+
+// Validate checks the Discord configuration without contacting Discord
+func (cfg *DiscordConfig) Validate() []ConfigError {
+	errs := validateWebhookURL(cfg.WebhookURL, "Discord")
+	return append(errs, validateTemplates(
+		[2]string{"content", cfg.Content},
+		[2]string{"embed.title", cfg.Embed.Title},
+		[2]string{"embed.description", cfg.Embed.Description},
+	)...)
+}
+
+func (cfg *DiscordConfig) Publisher() (Publisher, error) {
+	return NewDiscordPublisher(cfg)
+}
+
+func NewDiscordPublisher(cfg *DiscordConfig) (*Discord, error) {
+	u, err := url.Parse(cfg.WebhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid discord webhook url. %w", err)
+	}
+
+	return &Discord{
+		cfg:     cfg,
+		webhook: u,
+		client:  &http.Client{Timeout: WEBHOOK_REQUEST_TIMEOUT},
+	}, nil
+}
+
+/*
+DISCORD WEBHOOK OBJECT
+*/
+type Discord struct {
+	cfg     *DiscordConfig
+	webhook *url.URL
+	client  *http.Client
+}
+
+type discordMessage struct {
+	ID        string         `json:"id,omitempty"`
+	Content   string         `json:"content"`
+	Username  string         `json:"username,omitempty"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+	Embeds    []discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string              `json:"title,omitempty"`
+	Description string              `json:"description,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Timestamp   string              `json:"timestamp,omitempty"`
+	Color       int                 `json:"color,omitempty"`
+	Thumbnail   *discordEmbedImage  `json:"thumbnail,omitempty"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+}
+
+type discordEmbedImage struct {
+	URL string `json:"url"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// errDiscordMessageNotFound is returned when the message to edit or delete no longer exists
+var errDiscordMessageNotFound = errors.New("discord message not found")
+
+// Publish posts a new message announcing the broadcast
+func (d *Discord) Publish(broadcast *youtube.LiveBroadcast, publishVars interface{}) error {
+	_, err := d.PublishRef(broadcast, publishVars, "")
+	return err
+}
+
+// PublishRef posts a message announcing the broadcast, or edits the message with the ID ref when there is one. A new
+// message is posted if the message to edit was deleted. The ID of the message is returned
+func (d *Discord) PublishRef(broadcast *youtube.LiveBroadcast, publishVars interface{}, ref string) (string, error) {
+	msg, err := d.message(broadcast, publishVars)
+	if err != nil {
+		return ref, err
+	}
+
+	if ref != "" {
+		logging.YLSLogger().Debug("editing existing discord message for stream publish", zap.String("messageId", ref))
+		var edited discordMessage
+		err := d.do(http.MethodPatch, "messages/"+ref, msg, &edited)
+		if err == nil {
+			return ref, nil
+		}
+		if !errors.Is(err, errDiscordMessageNotFound) {
+			return ref, err
+		}
+		logging.YLSLogger().Warn("the discord message to edit no longer exists. posting a new message", zap.String("messageId", ref))
+	}
+
+	logging.YLSLogger().Debug("posting new discord message for stream publish", zap.String("title", broadcast.Snippet.Title))
+	var posted discordMessage
+	if err := d.do(http.MethodPost, "", msg, &posted); err != nil {
+		return "", err
+	}
+	return posted.ID, nil
+}
+
+// Unpublish cannot find the message of a broadcast without its ID, so there is nothing to remove
+func (d *Discord) Unpublish(broadcast *youtube.LiveBroadcast) error {
+	return d.UnpublishRef(broadcast, "")
+}
+
+// UnpublishRef deletes the message with the ID ref
+func (d *Discord) UnpublishRef(broadcast *youtube.LiveBroadcast, ref string) error {
+	if ref == "" {
+		logging.YLSLogger().Warn("nothing to unpublish. no discord message was recorded for the broadcast",
+			zap.String("broadcastId", broadcast.Id),
+		)
+		return nil
+	}
+
+	logging.YLSLogger().Debug("deleting discord message", zap.String("messageId", ref))
+	err := d.do(http.MethodDelete, "messages/"+ref, nil, nil)
+	if errors.Is(err, errDiscordMessageNotFound) {
+		return nil
+	}
+	return err
+}
+
+// message renders the configured message and embed for the broadcast
+func (d *Discord) message(broadcast *youtube.LiveBroadcast, publishVars interface{}) (*discordMessage, error) {
+	vars := newBroadcastVars(broadcast, publishVars)
+	start := vars.ScheduledStart
+
+	content, err := renderText("content", helper.DefaultValue(d.cfg.Content, DISCORD_DEFAULT_CONTENT, ""), vars)
+	if err != nil {
+		return nil, err
+	}
+	msg := &discordMessage{
+		Content:   content,
+		Username:  d.cfg.Username,
+		AvatarURL: d.cfg.AvatarURL,
+		Embeds:    []discordEmbed{},
+	}
+	if d.cfg.Embed.Disabled {
+		return msg, nil
+	}
+
+	title, err := renderText("embed.title", helper.DefaultValue(d.cfg.Embed.Title, "{{ .Broadcast.Snippet.Title }}", ""), vars)
+	if err != nil {
+		return nil, err
+	}
+	description, err := renderText("embed.description", d.cfg.Embed.Description, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	embed := discordEmbed{
+		Title:       title,
+		Description: description,
+		URL:         vars.ShareableLink,
+		Timestamp:   broadcast.Snippet.ScheduledStartTime,
+		Color:       d.cfg.Embed.Color,
+		Fields: []discordEmbedField{
+			{Name: "Scheduled Start", Value: fmt.Sprintf("<t:%d:F>", start.Unix()), Inline: true},
+			{Name: "Watch", Value: vars.ShareableLink, Inline: true},
+		},
+	}
+	if thumbnail := bestThumbnail(broadcast.Snippet.Thumbnails); thumbnail != "" {
+		embed.Thumbnail = &discordEmbedImage{URL: thumbnail}
+	}
+	msg.Embeds = append(msg.Embeds, embed)
+	return msg, nil
+}
+
+// do sends a request to the webhook, or to a path below it, and decodes the response into out
+func (d *Discord) do(method, path string, body, out interface{}) error {
+	u := *d.webhook
+	if path != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + path
+	}
+	if method == http.MethodPost {
+		// without wait, Discord does not return the message and its ID
+		q := u.Query()
+		q.Set("wait", "true")
+		u.RawQuery = q.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u.String(), reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send discord webhook request. %w", redactURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && path != "" {
+		return errDiscordMessageNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("discord webhook request failed with status %d. %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,226 @@
+package pub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+const testDiscordWebhookPath = "/api/webhooks/1234/token"
+
+type discordRequest struct {
+	Method string
+	Path   string
+	Wait   string
+	Body   discordMessage
+}
+
+// fakeDiscord serves the Discord webhook API, keeping the messages which have been posted and not deleted
+type fakeDiscord struct {
+	mu       sync.Mutex
+	nextId   int
+	messages map[string]discordMessage
+	requests []discordRequest
+}
+
+func newFakeDiscord(t *testing.T) (*fakeDiscord, *httptest.Server) {
+	t.Helper()
+
+	f := &fakeDiscord{messages: map[string]discordMessage{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeDiscord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	req := discordRequest{Method: r.Method, Path: r.URL.Path, Wait: r.URL.Query().Get("wait")}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req.Body)
+	}
+	f.requests = append(f.requests, req)
+
+	if r.Method == http.MethodPost && r.URL.Path == testDiscordWebhookPath {
+		f.nextId++
+		msg := req.Body
+		msg.ID = fmt.Sprintf("message-%d", f.nextId)
+		f.messages[msg.ID] = msg
+		json.NewEncoder(w).Encode(msg)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, testDiscordWebhookPath+"/messages/")
+	if _, ok := f.messages[id]; !ok || id == r.URL.Path {
+		http.Error(w, `{"message": "Unknown Message", "code": 10008}`, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		msg := req.Body
+		msg.ID = id
+		f.messages[id] = msg
+		json.NewEncoder(w).Encode(msg)
+	case http.MethodDelete:
+		delete(f.messages, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeDiscord) lastRequest(t *testing.T) discordRequest {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		t.Fatal("expected a request to the discord webhook")
+	}
+	return f.requests[len(f.requests)-1]
+}
+
+func testBroadcast() *youtube.LiveBroadcast {
+	return &youtube.LiveBroadcast{
+		Id: "broadcast-1",
+		Snippet: &youtube.LiveBroadcastSnippet{
+			Title:              "Sunday Service",
+			ScheduledStartTime: "2023-03-05T14:00:00Z",
+			Thumbnails: &youtube.ThumbnailDetails{
+				Default: &youtube.Thumbnail{Url: "https://example.com/default.jpg"},
+				High:    &youtube.Thumbnail{Url: "https://example.com/high.jpg"},
+			},
+		},
+	}
+}
+
+func newTestDiscord(t *testing.T, srv *httptest.Server) *Discord {
+	t.Helper()
+
+	d, err := NewDiscordPublisher(&DiscordConfig{WebhookURL: srv.URL + testDiscordWebhookPath, Username: "YLS"})
+	if err != nil {
+		t.Fatalf("failed to create discord publisher. %s", err)
+	}
+	return d
+}
+
+func TestDiscordPublishPostsMessage(t *testing.T) {
+	f, srv := newFakeDiscord(t)
+	d := newTestDiscord(t, srv)
+
+	ref, err := d.PublishRef(testBroadcast(), nil, "")
+	if err != nil {
+		t.Fatalf("failed to publish. %s", err)
+	}
+	if ref != "message-1" {
+		t.Errorf("expected the id of the posted message, got %q", ref)
+	}
+
+	req := f.lastRequest(t)
+	if req.Method != http.MethodPost || req.Path != testDiscordWebhookPath || req.Wait != "true" {
+		t.Errorf("expected POST %s?wait=true, got %s %s?wait=%s", testDiscordWebhookPath, req.Method, req.Path, req.Wait)
+	}
+	if want := "Sunday Service goes live <t:1678024800:F>"; req.Body.Content != want {
+		t.Errorf("expected content %q, got %q", want, req.Body.Content)
+	}
+	if req.Body.Username != "YLS" {
+		t.Errorf("expected username YLS, got %q", req.Body.Username)
+	}
+	if len(req.Body.Embeds) != 1 {
+		t.Fatalf("expected 1 embed, got %d", len(req.Body.Embeds))
+	}
+	embed := req.Body.Embeds[0]
+	if embed.URL != "https://youtube.com/live/broadcast-1?feature=share" {
+		t.Errorf("expected the embed to link to the broadcast, got %q", embed.URL)
+	}
+	if embed.Thumbnail == nil || embed.Thumbnail.URL != "https://example.com/high.jpg" {
+		t.Errorf("expected the embed to show the best thumbnail, got %+v", embed.Thumbnail)
+	}
+}
+
+func TestDiscordRepublishEditsMessage(t *testing.T) {
+	f, srv := newFakeDiscord(t)
+	d := newTestDiscord(t, srv)
+	b := testBroadcast()
+
+	ref, err := d.PublishRef(b, nil, "")
+	if err != nil {
+		t.Fatalf("failed to publish. %s", err)
+	}
+	b.Snippet.Title = "Sunday Service (Updated)"
+	edited, err := d.PublishRef(b, nil, ref)
+	if err != nil {
+		t.Fatalf("failed to republish. %s", err)
+	}
+	if edited != ref {
+		t.Errorf("expected the message %q to be kept, got %q", ref, edited)
+	}
+
+	req := f.lastRequest(t)
+	if want := testDiscordWebhookPath + "/messages/" + ref; req.Method != http.MethodPatch || req.Path != want {
+		t.Errorf("expected PATCH %s, got %s %s", want, req.Method, req.Path)
+	}
+	if n := len(f.messages); n != 1 {
+		t.Errorf("expected 1 message, got %d", n)
+	}
+	if title := f.messages[ref].Embeds[0].Title; title != b.Snippet.Title {
+		t.Errorf("expected the message to be edited with title %q, got %q", b.Snippet.Title, title)
+	}
+}
+
+func TestDiscordRepublishPostsWhenMessageDeleted(t *testing.T) {
+	f, srv := newFakeDiscord(t)
+	d := newTestDiscord(t, srv)
+
+	ref, err := d.PublishRef(testBroadcast(), nil, "message-deleted")
+	if err != nil {
+		t.Fatalf("failed to republish. %s", err)
+	}
+	if ref != "message-1" {
+		t.Errorf("expected the id of the new message, got %q", ref)
+	}
+
+	if len(f.requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(f.requests))
+	}
+	if patch := f.requests[0]; patch.Method != http.MethodPatch {
+		t.Errorf("expected the message to be edited first, got %s %s", patch.Method, patch.Path)
+	}
+	if post := f.requests[1]; post.Method != http.MethodPost || post.Wait != "true" {
+		t.Errorf("expected a new message to be posted, got %s %s?wait=%s", post.Method, post.Path, post.Wait)
+	}
+}
+
+func TestDiscordUnpublishDeletesMessage(t *testing.T) {
+	f, srv := newFakeDiscord(t)
+	d := newTestDiscord(t, srv)
+	b := testBroadcast()
+
+	ref, err := d.PublishRef(b, nil, "")
+	if err != nil {
+		t.Fatalf("failed to publish. %s", err)
+	}
+	if err := d.UnpublishRef(b, ref); err != nil {
+		t.Fatalf("failed to unpublish. %s", err)
+	}
+
+	req := f.lastRequest(t)
+	if want := testDiscordWebhookPath + "/messages/" + ref; req.Method != http.MethodDelete || req.Path != want {
+		t.Errorf("expected DELETE %s, got %s %s", want, req.Method, req.Path)
+	}
+	if n := len(f.messages); n != 0 {
+		t.Errorf("expected the message to be deleted, %d messages remain", n)
+	}
+
+	// the message is already gone
+	if err := d.UnpublishRef(b, ref); err != nil {
+		t.Errorf("expected unpublishing a deleted message to succeed, got %s", err)
+	}
+}
@@ -2,32 +2,226 @@ package pub
 
 import (
 	"fmt"
+	"strings"
 
 	"google.golang.org/api/youtube/v3"
-)
+	"gopkg.in/yaml.v3"
 
-const PUBLISHER_WORDPRESS string = "wordpress"
+	"sykesdev.ca/yls/pkg/helper"
+)
 
 type Publisher interface {
 	Publish(broadcast *youtube.LiveBroadcast, publishVars interface{}) error
+	// Unpublish removes (or hides) whatever was published for the broadcast, such as when it is cancelled
+	Unpublish(broadcast *youtube.LiveBroadcast) error
+}
+
+// RefPublisher is a Publisher which refers to what it published (such as a message ID), so that publishing a broadcast
+// again updates what was published instead of publishing it twice
+type RefPublisher interface {
+	Publisher
+	// PublishRef publishes the broadcast, or updates what was published as ref when ref is not empty. The ref of what
+	// was published is returned
+	PublishRef(broadcast *youtube.LiveBroadcast, publishVars interface{}, ref string) (string, error)
+	// UnpublishRef removes what was published as ref
+	UnpublishRef(broadcast *youtube.LiveBroadcast, ref string) error
+}
+
+// FailureNotifier is a Publisher which can also report the jobs of a stream that failed
+type FailureNotifier interface {
+	NotifyFailure(failure *JobFailure, publishVars interface{}) error
+}
+
+// JobFailure describes a job that failed, for publishers which notify about failures
+type JobFailure struct {
+	StreamName    string
+	OccurrenceKey string
+	// BroadcastID is empty when the broadcast could not be created
+	BroadcastID string
+	Steps       []FailedStep
+}
+
+type FailedStep struct {
+	Step  string
+	Error string
 }
 
+// the outcomes of a job that publishers fire on
+const (
+	PUBLISH_ON_SUCCESS = "success"
+	PUBLISH_ON_FAILURE = "failure"
+)
+
+var PUBLISH_ON_ALLOWED = []string{PUBLISH_ON_SUCCESS, PUBLISH_ON_FAILURE}
+
+// PublisherConfig is a single publish target of a stream. The configuration of the publisher is given under the key of
+// its type, such as `{type: wordpress, wordpress: {...}}`. When the type is omitted, it is inferred from that key
 type PublisherConfig struct {
-	Wordpress *WordpressConfig `yaml:"wordpress"`
+	Type string
+	Name string
+	// On are the outcomes of a job the publisher fires on. Defaults to success
+	On     []string
+	Config Config
+}
+
+// FiresOn reports whether the publisher fires when a job has the given outcome
+func (p *PublisherConfig) FiresOn(outcome string) bool {
+	if len(p.On) == 0 {
+		return outcome == PUBLISH_ON_SUCCESS
+	}
+	for _, on := range p.On {
+		if on == outcome {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PublisherConfig) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return typeErrorf(n, "expected a publisher mapping")
+	}
+
+	keys := []*yaml.Node{}
+	configs := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		switch key.Value {
+		case "type":
+			p.Type = value.Value
+		case "name":
+			p.Name = value.Value
+		case "on":
+			if err := value.Decode(&p.On); err != nil {
+				return err
+			}
+		default:
+			keys = append(keys, key)
+			configs[key.Value] = value
+		}
+	}
+
+	if p.Type == "" && len(keys) == 1 {
+		p.Type = keys[0].Value
+	}
+	if p.Type == "" {
+		return typeErrorf(n, "unknown publisher. must specify one of [%s]", strings.Join(Types(), ", "))
+	}
+	r, ok := Lookup(p.Type)
+	if !ok {
+		return typeErrorf(n, "unknown publisher type %q. must be one of [%s]", p.Type, strings.Join(Types(), ", "))
+	}
+	for _, key := range keys {
+		if key.Value != p.Type {
+			return typeErrorf(key, "unexpected %q configuration for a publisher of type %s", key.Value, p.Type)
+		}
+	}
+	config, ok := configs[p.Type]
+	if !ok {
+		return typeErrorf(n, "a %s configuration is required for publishers of type %s", p.Type, p.Type)
+	}
+
+	c, err := r.Factory(config)
+	if err != nil {
+		return err
+	}
+	p.Config = c
+	return nil
+}
+
+func typeErrorf(n *yaml.Node, format string, args ...interface{}) error {
+	return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %s", n.Line, fmt.Sprintf(format, args...))}}
 }
 
 func (p *PublisherConfig) GetPublisher() (Publisher, error) {
-	if p.Wordpress != nil {
-		return NewWordpressPublisher(p.Wordpress)
+	if p.Config == nil {
+		return nil, fmt.Errorf("no configuration for publisher %s", p)
+	}
+	return p.Config.Publisher()
+}
+
+// ConfigError describes a problem with a single field of a publisher configuration. Field is the path of the field
+// relative to the publisher configuration
+type ConfigError struct {
+	Field   string
+	Message string
+}
+
+// Validate checks the publisher configuration without contacting the publish target
+func (p *PublisherConfig) Validate() []ConfigError {
+	if p.Config == nil {
+		return []ConfigError{{Field: p.Type, Message: fmt.Sprintf("a %s configuration is required for publishers of type %s", p.Type, p.Type)}}
 	}
 
-	return nil, fmt.Errorf("unknown publisher")
+	errs := p.Config.Validate()
+	for i := range errs {
+		errs[i].Field = strings.TrimSuffix(p.Type+"."+errs[i].Field, ".")
+	}
+
+	events := []string{PUBLISH_ON_SUCCESS}
+	if r, ok := Lookup(p.Type); ok && len(r.Events) > 0 {
+		events = r.Events
+	}
+	for i, on := range p.On {
+		if helper.StringInSlice(on, PUBLISH_ON_ALLOWED) && !helper.StringInSlice(on, events) {
+			errs = append(errs, ConfigError{
+				Field:   fmt.Sprintf("on[%d]", i),
+				Message: fmt.Sprintf("publishers of type %s cannot fire on %s. must be one of [%s]", p.Type, on, strings.Join(events, ", ")),
+			})
+		}
+	}
+	return errs
 }
 
+// String returns the name of the publisher, which defaults to its type
 func (p *PublisherConfig) String() string {
-	if p.Wordpress != nil {
-		return "wordpress"
+	if p.Name != "" {
+		return p.Name
+	}
+	if p.Type != "" {
+		return p.Type
 	}
 
 	return "unknown"
 }
+
+// Publishers are the publish targets of a stream. For backwards compatibility, a single publisher configuration
+// given as a mapping (such as `publisher: {wordpress: ...}`) is decoded as a list of one publisher
+type Publishers []PublisherConfig
+
+func (ps *Publishers) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.MappingNode {
+		var p PublisherConfig
+		if err := n.Decode(&p); err != nil {
+			return err
+		}
+		*ps = Publishers{p}
+		return nil
+	}
+
+	var list []PublisherConfig
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*ps = list
+	return nil
+}
+
+// On returns the publishers which fire when a job has the given outcome
+func (ps Publishers) On(outcome string) []*PublisherConfig {
+	on := []*PublisherConfig{}
+	for i := range ps {
+		if ps[i].FiresOn(outcome) {
+			on = append(on, &ps[i])
+		}
+	}
+	return on
+}
+
+func (ps Publishers) String() string {
+	names := make([]string, 0, len(ps))
+	for i := range ps {
+		names = append(names, ps[i].String())
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,125 @@
+package pub
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+	"sykesdev.ca/yls/pkg/schema"
+)
+
+// Config is the configuration of a single publisher, decoded from the YAML node under the key of its type
+type Config interface {
+	// Publisher creates the publisher described by the configuration
+	Publisher() (Publisher, error)
+	// Validate checks the configuration without contacting the publish target
+	Validate() []ConfigError
+}
+
+// Factory decodes the configuration of a publisher type from its YAML node
+type Factory func(n *yaml.Node) (Config, error)
+
+// Registration describes a type of publisher that can be configured for a stream
+type Registration struct {
+	// Name is the type of the publisher, which is also the key of its configuration
+	Name        string
+	Description string
+	Factory     Factory
+	// ConfigType is the Go type the configuration is decoded into, if any. It is used to report unknown fields and
+	// invalid values with their position and to generate the JSON schema of the configuration
+	ConfigType reflect.Type
+	// Events are the outcomes of a job the publisher can fire on. Defaults to success. Publishers which fire on
+	// failure must implement FailureNotifier
+	Events []string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Registration{}
+)
+
+// Register makes a type of publisher available to streams configurations. Publishers built into YLS register
+// themselves, while programs embedding YLS may register their own before loading a configuration or generating its
+// schema. Registering the same name twice panics
+func Register(r Registration) {
+	if r.Name == "" || r.Factory == nil {
+		panic("pub: a publisher registration requires a name and a factory")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[r.Name]; ok {
+		panic(fmt.Sprintf("pub: publisher type %q is already registered", r.Name))
+	}
+	registry[r.Name] = r
+}
+
+// Lookup returns the registration of a publisher type
+func Lookup(name string) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Types returns the names of every registered publisher type
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registeredTypes()
+}
+
+func registeredTypes() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Schema describes the publishers of a stream, given either as a single publisher or as a list of publishers
+func Schema() *schema.JSONSchema {
+	p := publisherSchema()
+	return &schema.JSONSchema{
+		OneOf: []*schema.JSONSchema{p, {Type: "array", Items: p}},
+	}
+}
+
+// publisherSchema describes a publisher entry: its type, name and the configuration of each registered publisher type
+func publisherSchema() *schema.JSONSchema {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	s := &schema.JSONSchema{
+		Type: "object",
+		Properties: map[string]*schema.JSONSchema{
+			"type": {
+				Type:        "string",
+				Description: "Type of the publisher. Inferred from the publisher configuration when omitted",
+				Enum:        registeredTypes(),
+			},
+			"name": {
+				Type:        "string",
+				Description: "Name of the publisher in logs and job results, unique within the stream. Defaults to the type",
+			},
+			"on": {
+				Type:        "array",
+				Description: "Outcomes of a job the publisher fires on. Defaults to success. Only some publishers can fire on failure",
+				Items:       &schema.JSONSchema{Type: "string", Enum: PUBLISH_ON_ALLOWED},
+			},
+		},
+		AdditionalProperties: false,
+	}
+	for name, r := range registry {
+		p := &schema.JSONSchema{}
+		if r.ConfigType != nil {
+			p = schema.Generate(r.ConfigType)
+		}
+		p.Description = r.Description
+		s.Properties[name] = p
+	}
+	return s
+}
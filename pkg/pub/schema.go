@@ -0,0 +1,66 @@
+package pub
+
+import (
+	"reflect"
+
+	"sykesdev.ca/yls/pkg/schema"
+)
+
+func init() {
+	schema.Annotate(reflect.TypeOf(WordpressConfig{}), map[string]schema.Field{
+		"host":           {Description: "Hostname of the Wordpress site", Required: true},
+		"port":           {Description: "Port of the Wordpress site"},
+		"tls":            {Description: "Connect to the Wordpress site using HTTPS"},
+		"username":       {Description: "Wordpress user to publish as", Required: true},
+		"appToken":       {Description: "Wordpress application password of the user", Required: true},
+		"existingPageID": {Description: "Unused. See data.meta.existingId"},
+		"data":           {Description: "The content to publish"},
+	})
+
+	schema.Annotate(reflect.TypeOf(WordpressData{}), map[string]schema.Field{
+		"meta":    {Description: "Metadata of the published page or post"},
+		"content": {Description: "Content of the page or post. Rendered as an HTML template with sprig functions, given .Broadcast and .ExtraVars"},
+	})
+
+	schema.Annotate(reflect.TypeOf(WordpressMeta{}), map[string]schema.Field{
+		"existingId":     {Description: "Update this existing page or post instead of creating a new one"},
+		"type":           {Description: "Type of content to publish", Enum: CONTENT_TYPES_ALLOWED},
+		"titleOverride":  {Description: "Title of the page, instead of the broadcast title"},
+		"slug":           {Description: "Slug of the page or post"},
+		"password":       {Description: "Password protecting the page or post"},
+		"status":         {Description: "Status of the page or post", Enum: CONTENT_STATUSES_ALLOWED},
+		"comment_status": {Description: "Whether comments are open or closed"},
+		"parent":         {Description: "ID of the parent page"},
+		"author":         {Description: "ID of the author, instead of the authenticated user"},
+		"featured_image": {Description: "Unused"},
+	})
+
+	schema.Annotate(reflect.TypeOf(DiscordConfig{}), map[string]schema.Field{
+		"webhookUrl": {Description: "URL of the Discord webhook, including its token", Required: true},
+		"username":   {Description: "Name the message is posted as, instead of the name of the webhook"},
+		"avatarUrl":  {Description: "URL of the avatar the message is posted with, instead of the avatar of the webhook"},
+		"content":    {Description: "Text of the message. Rendered as a text template with sprig functions, given .Broadcast, .ScheduledStart, .ShareableLink and .ExtraVars"},
+		"embed":      {Description: "Embed of the broadcast posted with the message"},
+	})
+
+	schema.Annotate(reflect.TypeOf(DiscordEmbedConfig{}), map[string]schema.Field{
+		"disabled":    {Description: "Post the message without an embed"},
+		"title":       {Description: "Title of the embed, instead of the broadcast title. Rendered as a text template"},
+		"description": {Description: "Description of the embed. Rendered as a text template"},
+		"color":       {Description: "Color of the embed as a decimal RGB value", Examples: []interface{}{16711680}},
+	})
+
+	schema.Annotate(reflect.TypeOf(SlackConfig{}), map[string]schema.Field{
+		"webhookUrl":  {Description: "URL of the Slack incoming webhook", Required: true},
+		"text":        {Description: "Text of the message. Rendered as a text template with sprig functions, given .Broadcast, .ScheduledStart, .ShareableLink and .ExtraVars"},
+		"blocks":      {Description: "Block Kit blocks of the message, instead of the default layout. Rendered as a text template which must produce a JSON array"},
+		"failureText": {Description: "Text of the message posted when a job fails. Rendered as a text template given .Failure, .ShareableLink and .ExtraVars"},
+	})
+
+	schema.Annotate(reflect.TypeOf(TeamsConfig{}), map[string]schema.Field{
+		"webhookUrl":  {Description: "URL of the Teams incoming webhook", Required: true},
+		"text":        {Description: "Title of the card. Rendered as a text template with sprig functions, given .Broadcast, .ScheduledStart, .ShareableLink and .ExtraVars"},
+		"card":        {Description: "Adaptive Card to post, instead of the default card. Rendered as a text template which must produce a JSON object"},
+		"failureText": {Description: "Title of the card posted when a job fails. Rendered as a text template given .Failure, .ShareableLink and .ExtraVars"},
+	})
+}
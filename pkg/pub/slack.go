@@ -0,0 +1,176 @@
+package pub
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/youtube/v3"
+	"gopkg.in/yaml.v3"
+
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+const PUBLISHER_SLACK string = "slack"
+
+const (
+	SLACK_DEFAULT_TEXT         = "A new broadcast was scheduled: {{ .Broadcast.Snippet.Title }}"
+	SLACK_DEFAULT_FAILURE_TEXT = ":warning: the job of stream {{ .Failure.StreamName }} failed"
+)
+
+func init() {
+	Register(Registration{
+		Name:        PUBLISHER_SLACK,
+		Description: "Notify a Slack channel using an incoming webhook",
+		Factory: func(n *yaml.Node) (Config, error) {
+			cfg := &SlackConfig{}
+			if err := n.Decode(cfg); err != nil {
+				return nil, err
+			}
+			return cfg, nil
+		},
+		ConfigType: reflect.TypeOf(SlackConfig{}),
+		Events:     PUBLISH_ON_ALLOWED,
+	})
+}
+
+type SlackConfig struct {
+	WebhookURL  string `yaml:"webhookUrl" json:"-"`
+	Text        string `yaml:"text,omitempty"`
+	Blocks      string `yaml:"blocks,omitempty"`
+	FailureText string `yaml:"failureText,omitempty"`
+}
+
+// Validate checks the Slack configuration without contacting Slack
+func (cfg *SlackConfig) Validate() []ConfigError {
+	errs := validateWebhookURL(cfg.WebhookURL, "Slack")
+	return append(errs, validateTemplates(
+		[2]string{"text", cfg.Text},
+		[2]string{"blocks", cfg.Blocks},
+		[2]string{"failureText", cfg.FailureText},
+	)...)
+}
+
+func (cfg *SlackConfig) Publisher() (Publisher, error) {
+	return NewSlackPublisher(cfg), nil
+}
+
+func NewSlackPublisher(cfg *SlackConfig) *Slack {
+	return &Slack{
+		cfg:    cfg,
+		client: &http.Client{Timeout: WEBHOOK_REQUEST_TIMEOUT},
+	}
+}
+
+/*
+SLACK WEBHOOK OBJECT
+*/
+type Slack struct {
+	cfg    *SlackConfig
+	client *http.Client
+}
+
+type slackMessage struct {
+	Text   string      `json:"text"`
+	Blocks interface{} `json:"blocks,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Publish posts a message announcing the scheduled broadcast. Unless custom blocks are configured, the message is a
+// Block Kit layout with the title, scheduled start, thumbnail and share link of the broadcast
+func (s *Slack) Publish(broadcast *youtube.LiveBroadcast, publishVars interface{}) error {
+	vars := newBroadcastVars(broadcast, publishVars)
+	text, err := renderText("text", helper.DefaultValue(s.cfg.Text, SLACK_DEFAULT_TEXT, ""), vars)
+	if err != nil {
+		return err
+	}
+
+	msg := &slackMessage{Text: text}
+	if s.cfg.Blocks != "" {
+		if msg.Blocks, err = renderJSON("blocks", s.cfg.Blocks, vars); err != nil {
+			return err
+		}
+	} else {
+		msg.Blocks = s.blocks(vars, text)
+	}
+
+	logging.YLSLogger().Debug("posting slack message for stream publish", zap.String("text", text))
+	return postJSON(s.client, s.cfg.WebhookURL, msg)
+}
+
+func (s *Slack) blocks(vars *BroadcastVars, text string) []interface{} {
+	section := map[string]interface{}{
+		"type": "section",
+		"text": slackText{
+			Type: "mrkdwn",
+			Text: fmt.Sprintf("%s\n*Starts:* <!date^%d^{date_long_pretty} at {time}|%s>",
+				text,
+				vars.ScheduledStart.Unix(),
+				vars.ScheduledStart.Format("Mon Jan 2 15:04 MST"),
+			),
+		},
+	}
+	if thumbnail := bestThumbnail(vars.Broadcast.Snippet.Thumbnails); thumbnail != "" {
+		section["accessory"] = map[string]string{
+			"type":      "image",
+			"image_url": thumbnail,
+			"alt_text":  vars.Broadcast.Snippet.Title,
+		}
+	}
+
+	return []interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": slackText{Type: "plain_text", Text: vars.Broadcast.Snippet.Title},
+		},
+		section,
+		map[string]interface{}{
+			"type": "actions",
+			"elements": []interface{}{
+				map[string]interface{}{
+					"type": "button",
+					"text": slackText{Type: "plain_text", Text: "Watch"},
+					"url":  vars.ShareableLink,
+				},
+			},
+		},
+	}
+}
+
+// Unpublish does nothing, since messages posted by incoming webhooks cannot be deleted
+func (s *Slack) Unpublish(broadcast *youtube.LiveBroadcast) error {
+	logging.YLSLogger().Warn("nothing to unpublish. slack messages posted by incoming webhooks cannot be deleted",
+		zap.String("broadcastId", broadcast.Id),
+	)
+	return nil
+}
+
+// NotifyFailure posts a message reporting the failed steps of a job
+func (s *Slack) NotifyFailure(failure *JobFailure, publishVars interface{}) error {
+	vars := newFailureVars(failure, publishVars)
+	text, err := renderText("failureText", helper.DefaultValue(s.cfg.FailureText, SLACK_DEFAULT_FAILURE_TEXT, ""), vars)
+	if err != nil {
+		return err
+	}
+
+	details := "```" + failure.Summary() + "```"
+	if vars.ShareableLink != "" {
+		details = fmt.Sprintf("<%s|broadcast %s>\n%s", vars.ShareableLink, failure.BroadcastID, details)
+	}
+	msg := &slackMessage{
+		Text: text,
+		Blocks: []interface{}{
+			map[string]interface{}{"type": "section", "text": slackText{Type: "mrkdwn", Text: text}},
+			map[string]interface{}{"type": "section", "text": slackText{Type: "mrkdwn", Text: details}},
+		},
+	}
+
+	logging.YLSLogger().Debug("posting slack message for failed job", zap.String("text", text))
+	return postJSON(s.client, s.cfg.WebhookURL, msg)
+}
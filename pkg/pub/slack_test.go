@@ -0,0 +1,93 @@
+package pub
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlackPublishPayload(t *testing.T) {
+	rec, srv := newWebhookRecorder(t)
+	s := NewSlackPublisher(&SlackConfig{WebhookURL: srv.URL})
+
+	if err := s.Publish(testBroadcast(), nil); err != nil {
+		t.Fatalf("failed to publish. %s", err)
+	}
+	payload := rec.only(t)
+
+	if want := "A new broadcast was scheduled: Sunday Service"; payload["text"] != want {
+		t.Errorf("expected text %q, got %v", want, payload["text"])
+	}
+	if got := lookup(t, payload, "blocks", 0, "type"); got != "header" {
+		t.Errorf("expected a header block, got %v", got)
+	}
+	if got := lookup(t, payload, "blocks", 0, "text", "text"); got != "Sunday Service" {
+		t.Errorf("expected the header to show the title, got %v", got)
+	}
+	section, _ := lookup(t, payload, "blocks", 1, "text", "text").(string)
+	if !strings.Contains(section, "<!date^1678024800^") {
+		t.Errorf("expected the section to format the scheduled start for each reader, got %q", section)
+	}
+	if got := lookup(t, payload, "blocks", 1, "accessory", "image_url"); got != "https://example.com/high.jpg" {
+		t.Errorf("expected the section to show the best thumbnail, got %v", got)
+	}
+	if got := lookup(t, payload, "blocks", 2, "elements", 0, "url"); got != shareableLink("broadcast-1") {
+		t.Errorf("expected the button to link to the broadcast, got %v", got)
+	}
+}
+
+func TestSlackPublishCustomBlocks(t *testing.T) {
+	rec, srv := newWebhookRecorder(t)
+	s := NewSlackPublisher(&SlackConfig{
+		WebhookURL: srv.URL,
+		Text:       "{{ .Broadcast.Snippet.Title }} is coming up",
+		Blocks:     `[{"type": "section", "text": {"type": "mrkdwn", "text": {{ .ShareableLink | toJson }}}}]`,
+	})
+
+	if err := s.Publish(testBroadcast(), nil); err != nil {
+		t.Fatalf("failed to publish. %s", err)
+	}
+	payload := rec.only(t)
+
+	if payload["text"] != "Sunday Service is coming up" {
+		t.Errorf("expected the custom text, got %v", payload["text"])
+	}
+	if blocks, _ := payload["blocks"].([]interface{}); len(blocks) != 1 {
+		t.Fatalf("expected only the custom block, got %v", payload["blocks"])
+	}
+	if got := lookup(t, payload, "blocks", 0, "text", "text"); got != shareableLink("broadcast-1") {
+		t.Errorf("expected the custom block to be rendered, got %v", got)
+	}
+}
+
+func TestSlackNotifyFailurePayload(t *testing.T) {
+	rec, srv := newWebhookRecorder(t)
+	s := NewSlackPublisher(&SlackConfig{WebhookURL: srv.URL})
+
+	if err := s.NotifyFailure(testFailure(), nil); err != nil {
+		t.Fatalf("failed to notify. %s", err)
+	}
+	payload := rec.only(t)
+
+	if want := ":warning: the job of stream sunday-service failed"; payload["text"] != want {
+		t.Errorf("expected text %q, got %v", want, payload["text"])
+	}
+	details, _ := lookup(t, payload, "blocks", 1, "text", "text").(string)
+	if !strings.Contains(details, "thumbnails: failed to set thumbnail") {
+		t.Errorf("expected the failed steps to be listed, got %q", details)
+	}
+	if !strings.Contains(details, shareableLink("broadcast-1")) {
+		t.Errorf("expected a link to the broadcast, got %q", details)
+	}
+}
+
+func TestSlackWebhookError(t *testing.T) {
+	s := NewSlackPublisher(&SlackConfig{WebhookURL: "http://127.0.0.1:0/services/secret"})
+
+	err := s.Publish(testBroadcast(), nil)
+	if err == nil {
+		t.Fatal("expected an error when the webhook cannot be reached")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected the webhook url to be redacted, got %q", err)
+	}
+}
@@ -0,0 +1,189 @@
+package pub
+
+import (
+	"net/http"
+	"reflect"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/youtube/v3"
+	"gopkg.in/yaml.v3"
+
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+const PUBLISHER_TEAMS string = "teams"
+
+const (
+	TEAMS_DEFAULT_TEXT         = "{{ .Broadcast.Snippet.Title }} was scheduled"
+	TEAMS_DEFAULT_FAILURE_TEXT = "The job of stream {{ .Failure.StreamName }} failed"
+
+	ADAPTIVE_CARD_CONTENT_TYPE = "application/vnd.microsoft.card.adaptive"
+	ADAPTIVE_CARD_SCHEMA       = "http://adaptivecards.io/schemas/adaptive-card.json"
+	ADAPTIVE_CARD_VERSION      = "1.4"
+)
+
+func init() {
+	Register(Registration{
+		Name:        PUBLISHER_TEAMS,
+		Description: "Notify a Microsoft Teams channel using an incoming webhook",
+		Factory: func(n *yaml.Node) (Config, error) {
+			cfg := &TeamsConfig{}
+			if err := n.Decode(cfg); err != nil {
+				return nil, err
+			}
+			return cfg, nil
+		},
+		ConfigType: reflect.TypeOf(TeamsConfig{}),
+		Events:     PUBLISH_ON_ALLOWED,
+	})
+}
+
+type TeamsConfig struct {
+	WebhookURL  string `yaml:"webhookUrl" json:"-"`
+	Text        string `yaml:"text,omitempty"`
+	Card        string `yaml:"card,omitempty"`
+	FailureText string `yaml:"failureText,omitempty"`
+}
+
+// Validate checks the Teams configuration without contacting Teams
+func (cfg *TeamsConfig) Validate() []ConfigError {
+	errs := validateWebhookURL(cfg.WebhookURL, "Teams")
+	return append(errs, validateTemplates(
+		[2]string{"text", cfg.Text},
+		[2]string{"card", cfg.Card},
+		[2]string{"failureText", cfg.FailureText},
+	)...)
+}
+
+func (cfg *TeamsConfig) Publisher() (Publisher, error) {
+	return NewTeamsPublisher(cfg), nil
+}
+
+func NewTeamsPublisher(cfg *TeamsConfig) *Teams {
+	return &Teams{
+		cfg:    cfg,
+		client: &http.Client{Timeout: WEBHOOK_REQUEST_TIMEOUT},
+	}
+}
+
+/*
+TEAMS WEBHOOK OBJECT
+*/
+type Teams struct {
+	cfg    *TeamsConfig
+	client *http.Client
+}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string      `json:"contentType"`
+	Content     interface{} `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string                   `json:"$schema"`
+	Type    string                   `json:"type"`
+	Version string                   `json:"version"`
+	Body    []map[string]interface{} `json:"body"`
+	Actions []map[string]interface{} `json:"actions,omitempty"`
+}
+
+func newAdaptiveCard() *adaptiveCard {
+	return &adaptiveCard{
+		Schema:  ADAPTIVE_CARD_SCHEMA,
+		Type:    "AdaptiveCard",
+		Version: ADAPTIVE_CARD_VERSION,
+	}
+}
+
+func textBlock(text string, extra map[string]interface{}) map[string]interface{} {
+	block := map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true}
+	for k, v := range extra {
+		block[k] = v
+	}
+	return block
+}
+
+// Publish posts an Adaptive Card announcing the scheduled broadcast. Unless a custom card is configured, the card shows
+// the title, scheduled start, thumbnail and share link of the broadcast
+func (t *Teams) Publish(broadcast *youtube.LiveBroadcast, publishVars interface{}) error {
+	vars := newBroadcastVars(broadcast, publishVars)
+
+	var card interface{}
+	if t.cfg.Card != "" {
+		c, err := renderJSON("card", t.cfg.Card, vars)
+		if err != nil {
+			return err
+		}
+		card = c
+	} else {
+		text, err := renderText("text", helper.DefaultValue(t.cfg.Text, TEAMS_DEFAULT_TEXT, ""), vars)
+		if err != nil {
+			return err
+		}
+
+		c := newAdaptiveCard()
+		c.Body = append(c.Body,
+			textBlock(text, map[string]interface{}{"size": "Large", "weight": "Bolder"}),
+			// Teams formats the date and time in the time zone of each reader
+			textBlock("Starts {{DATE("+broadcast.Snippet.ScheduledStartTime+", LONG)}} at {{TIME("+broadcast.Snippet.ScheduledStartTime+")}}", nil),
+		)
+		if thumbnail := bestThumbnail(broadcast.Snippet.Thumbnails); thumbnail != "" {
+			c.Body = append(c.Body, map[string]interface{}{"type": "Image", "url": thumbnail, "altText": broadcast.Snippet.Title})
+		}
+		c.Actions = append(c.Actions, map[string]interface{}{"type": "Action.OpenUrl", "title": "Watch", "url": vars.ShareableLink})
+		card = c
+	}
+
+	logging.YLSLogger().Debug("posting teams card for stream publish", zap.String("title", broadcast.Snippet.Title))
+	return postJSON(t.client, t.cfg.WebhookURL, teamsPayload(card))
+}
+
+// Unpublish does nothing, since messages posted by incoming webhooks cannot be deleted
+func (t *Teams) Unpublish(broadcast *youtube.LiveBroadcast) error {
+	logging.YLSLogger().Warn("nothing to unpublish. teams messages posted by incoming webhooks cannot be deleted",
+		zap.String("broadcastId", broadcast.Id),
+	)
+	return nil
+}
+
+// NotifyFailure posts an Adaptive Card reporting the failed steps of a job
+func (t *Teams) NotifyFailure(failure *JobFailure, publishVars interface{}) error {
+	vars := newFailureVars(failure, publishVars)
+	text, err := renderText("failureText", helper.DefaultValue(t.cfg.FailureText, TEAMS_DEFAULT_FAILURE_TEXT, ""), vars)
+	if err != nil {
+		return err
+	}
+
+	facts := []map[string]string{}
+	for _, s := range failure.Steps {
+		facts = append(facts, map[string]string{"title": s.Step, "value": s.Error})
+	}
+	c := newAdaptiveCard()
+	c.Body = append(c.Body,
+		textBlock(text, map[string]interface{}{"size": "Large", "weight": "Bolder", "color": "Attention"}),
+		map[string]interface{}{"type": "FactSet", "facts": facts},
+	)
+	if vars.ShareableLink != "" {
+		c.Actions = append(c.Actions, map[string]interface{}{"type": "Action.OpenUrl", "title": "Open Broadcast", "url": vars.ShareableLink})
+	}
+
+	logging.YLSLogger().Debug("posting teams card for failed job", zap.String("text", text))
+	return postJSON(t.client, t.cfg.WebhookURL, teamsPayload(c))
+}
+
+// teamsPayload wraps an Adaptive Card in the message expected by Teams incoming webhooks
+func teamsPayload(card interface{}) *teamsMessage {
+	return &teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: ADAPTIVE_CARD_CONTENT_TYPE,
+			Content:     card,
+		}},
+	}
+}
@@ -0,0 +1,83 @@
+package pub
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTeamsPublishPayload(t *testing.T) {
+	rec, srv := newWebhookRecorder(t)
+	tm := NewTeamsPublisher(&TeamsConfig{WebhookURL: srv.URL})
+
+	if err := tm.Publish(testBroadcast(), nil); err != nil {
+		t.Fatalf("failed to publish. %s", err)
+	}
+	payload := rec.only(t)
+
+	if payload["type"] != "message" {
+		t.Errorf("expected a message, got %v", payload["type"])
+	}
+	if got := lookup(t, payload, "attachments", 0, "contentType"); got != ADAPTIVE_CARD_CONTENT_TYPE {
+		t.Errorf("expected an adaptive card attachment, got %v", got)
+	}
+	card := lookup(t, payload, "attachments", 0, "content")
+	if got := lookup(t, card, "version"); got != ADAPTIVE_CARD_VERSION {
+		t.Errorf("expected card version %s, got %v", ADAPTIVE_CARD_VERSION, got)
+	}
+	if got := lookup(t, card, "body", 0, "text"); got != "Sunday Service was scheduled" {
+		t.Errorf("expected the card title, got %v", got)
+	}
+	start, _ := lookup(t, card, "body", 1, "text").(string)
+	if !strings.Contains(start, "{{DATE(2023-03-05T14:00:00Z, LONG)}}") {
+		t.Errorf("expected the scheduled start to be formatted by Teams, got %q", start)
+	}
+	if got := lookup(t, card, "body", 2, "url"); got != "https://example.com/high.jpg" {
+		t.Errorf("expected the card to show the best thumbnail, got %v", got)
+	}
+	if got := lookup(t, card, "actions", 0, "url"); got != shareableLink("broadcast-1") {
+		t.Errorf("expected the action to open the broadcast, got %v", got)
+	}
+}
+
+func TestTeamsPublishCustomCard(t *testing.T) {
+	rec, srv := newWebhookRecorder(t)
+	tm := NewTeamsPublisher(&TeamsConfig{
+		WebhookURL: srv.URL,
+		Card:       `{"type": "AdaptiveCard", "version": "1.2", "body": [{"type": "TextBlock", "text": {{ .Broadcast.Snippet.Title | toJson }}}]}`,
+	})
+
+	if err := tm.Publish(testBroadcast(), nil); err != nil {
+		t.Fatalf("failed to publish. %s", err)
+	}
+	card := lookup(t, rec.only(t), "attachments", 0, "content")
+
+	if got := lookup(t, card, "version"); got != "1.2" {
+		t.Errorf("expected the custom card, got version %v", got)
+	}
+	if got := lookup(t, card, "body", 0, "text"); got != "Sunday Service" {
+		t.Errorf("expected the custom card to be rendered, got %v", got)
+	}
+}
+
+func TestTeamsNotifyFailurePayload(t *testing.T) {
+	rec, srv := newWebhookRecorder(t)
+	tm := NewTeamsPublisher(&TeamsConfig{WebhookURL: srv.URL})
+
+	if err := tm.NotifyFailure(testFailure(), nil); err != nil {
+		t.Fatalf("failed to notify. %s", err)
+	}
+	card := lookup(t, rec.only(t), "attachments", 0, "content")
+
+	if got := lookup(t, card, "body", 0, "text"); got != "The job of stream sunday-service failed" {
+		t.Errorf("expected the failure title, got %v", got)
+	}
+	if got := lookup(t, card, "body", 1, "facts", 0, "title"); got != "thumbnails" {
+		t.Errorf("expected a fact for the failed step, got %v", got)
+	}
+	if got := lookup(t, card, "body", 1, "facts", 0, "value"); got != "failed to set thumbnail" {
+		t.Errorf("expected the error of the failed step, got %v", got)
+	}
+	if got := lookup(t, card, "actions", 0, "url"); got != shareableLink("broadcast-1") {
+		t.Errorf("expected the action to open the broadcast, got %v", got)
+	}
+}
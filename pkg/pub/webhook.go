@@ -0,0 +1,153 @@
+package pub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig"
+	"google.golang.org/api/youtube/v3"
+)
+
+const WEBHOOK_REQUEST_TIMEOUT = 30 * time.Second
+
+// BroadcastVars are given to the templates of the webhook publishers. Like the Wordpress template, they are given the
+// broadcast and the stream as .ExtraVars
+type BroadcastVars struct {
+	Broadcast      *youtube.LiveBroadcast
+	ScheduledStart time.Time
+	ShareableLink  string
+	ExtraVars      interface{}
+}
+
+func newBroadcastVars(broadcast *youtube.LiveBroadcast, publishVars interface{}) *BroadcastVars {
+	start, _ := time.Parse(time.RFC3339, broadcast.Snippet.ScheduledStartTime)
+	return &BroadcastVars{
+		Broadcast:      broadcast,
+		ScheduledStart: start,
+		ShareableLink:  shareableLink(broadcast.Id),
+		ExtraVars:      publishVars,
+	}
+}
+
+// FailureVars are given to the failure templates of the webhook publishers
+type FailureVars struct {
+	Failure *JobFailure
+	// ShareableLink is empty when the broadcast could not be created
+	ShareableLink string
+	ExtraVars     interface{}
+}
+
+func newFailureVars(failure *JobFailure, publishVars interface{}) *FailureVars {
+	vars := &FailureVars{Failure: failure, ExtraVars: publishVars}
+	if failure.BroadcastID != "" {
+		vars.ShareableLink = shareableLink(failure.BroadcastID)
+	}
+	return vars
+}
+
+// Summary describes the failed steps of the job on one line each
+func (f *JobFailure) Summary() string {
+	lines := make([]string, 0, len(f.Steps))
+	for _, s := range f.Steps {
+		lines = append(lines, fmt.Sprintf("%s: %s", s.Step, s.Error))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func shareableLink(broadcastId string) string {
+	return fmt.Sprintf("https://youtube.com/live/%s?feature=share", broadcastId)
+}
+
+// postJSON posts a JSON payload to a webhook
+func postJSON(client *http.Client, webhookUrl string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(webhookUrl, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to send webhook request. %w", redactURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook request failed with status %d. %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// redactURL removes the URL from request errors, since the URL of a webhook includes its secret token
+func redactURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// validateWebhookURL checks that a webhook URL is an absolute http(s) URL
+func validateWebhookURL(webhookUrl, service string) []ConfigError {
+	if u, err := url.Parse(webhookUrl); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return []ConfigError{{Field: "webhookUrl", Message: fmt.Sprintf("must be the http(s) URL of a %s webhook", service)}}
+	}
+	return []ConfigError{}
+}
+
+// validateTemplates checks the syntax of text templates, given by the name of their field
+func validateTemplates(templates ...[2]string) []ConfigError {
+	errs := []ConfigError{}
+	for _, t := range templates {
+		if _, err := template.New(t[0]).Funcs(sprig.TxtFuncMap()).Parse(t[1]); err != nil {
+			errs = append(errs, ConfigError{Field: t[0], Message: fmt.Sprintf("invalid template. %s", err)})
+		}
+	}
+	return errs
+}
+
+// renderText renders a text template with sprig functions
+func renderText(name, text string, vars interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(sprig.TxtFuncMap()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var res bytes.Buffer
+	if err := tmpl.Execute(&res, vars); err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
+
+// renderJSON renders a text template which produces JSON, such as a custom Block Kit or Adaptive Card payload
+func renderJSON(name, text string, vars interface{}) (json.RawMessage, error) {
+	res, err := renderText(name, text, vars)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid([]byte(res)) {
+		return nil, fmt.Errorf("the %s template did not render valid JSON", name)
+	}
+	return json.RawMessage(res), nil
+}
+
+// bestThumbnail returns the URL of the largest thumbnail of a broadcast, if any
+func bestThumbnail(t *youtube.ThumbnailDetails) string {
+	if t == nil {
+		return ""
+	}
+	for _, th := range []*youtube.Thumbnail{t.Maxres, t.High, t.Standard, t.Medium, t.Default} {
+		if th != nil && th.Url != "" {
+			return th.Url
+		}
+	}
+	return ""
+}
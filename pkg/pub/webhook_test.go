@@ -0,0 +1,83 @@
+package pub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// webhookRecorder collects the JSON payloads posted to an incoming webhook
+type webhookRecorder struct {
+	mu       sync.Mutex
+	payloads []map[string]interface{}
+}
+
+func newWebhookRecorder(t *testing.T) (*webhookRecorder, *httptest.Server) {
+	t.Helper()
+
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+			return
+		}
+		rec.mu.Lock()
+		rec.payloads = append(rec.payloads, payload)
+		rec.mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return rec, srv
+}
+
+func (rec *webhookRecorder) only(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.payloads) != 1 {
+		t.Fatalf("expected 1 webhook payload, got %d", len(rec.payloads))
+	}
+	return rec.payloads[0]
+}
+
+// lookup follows a path of object keys and array indexes through a decoded JSON payload
+func lookup(t *testing.T, v interface{}, path ...interface{}) interface{} {
+	t.Helper()
+
+	for i, p := range path {
+		switch p := p.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				t.Fatalf("expected an object at %v, got %T", path[:i], v)
+			}
+			v = m[p]
+		case int:
+			a, ok := v.([]interface{})
+			if !ok || p >= len(a) {
+				t.Fatalf("expected an array with an element %d at %v, got %v", p, path[:i], v)
+			}
+			v = a[p]
+		}
+	}
+	return v
+}
+
+func testFailure() *JobFailure {
+	return &JobFailure{
+		StreamName:    "sunday-service",
+		OccurrenceKey: "2023-03-05T09:00",
+		BroadcastID:   "broadcast-1",
+		Steps: []FailedStep{
+			{Step: "thumbnails", Error: "failed to set thumbnail"},
+		},
+	}
+}
@@ -4,23 +4,45 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"net/url"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/Masterminds/sprig"
 	"github.com/sogko/go-wordpress"
 	"go.uber.org/zap"
 	"google.golang.org/api/youtube/v3"
+	"gopkg.in/yaml.v3"
 
+	"sykesdev.ca/yls/pkg/helper"
 	"sykesdev.ca/yls/pkg/logging"
 )
 
+const PUBLISHER_WORDPRESS string = "wordpress"
+
+func init() {
+	Register(Registration{
+		Name:        PUBLISHER_WORDPRESS,
+		Description: "Publish each broadcast to a Wordpress page or post",
+		Factory: func(n *yaml.Node) (Config, error) {
+			cfg := &WordpressConfig{}
+			if err := n.Decode(cfg); err != nil {
+				return nil, err
+			}
+			return cfg, nil
+		},
+		ConfigType: reflect.TypeOf(WordpressConfig{}),
+	})
+}
+
 type WordpressConfig struct {
 	// Connection
 	Host     string `yaml:"host"`
 	Port     string `yaml:"port"`
 	TLS      bool   `yaml:"tls"`
 	Username string `yaml:"username"`
-	AppToken string `yaml:"appToken"`
+	AppToken string `yaml:"appToken" json:"-"`
 	// Indexing preferences
 	ExistingPageId int `yaml:"existingPageID,omitempty"`
 	// Wordpress payload data
@@ -53,6 +75,28 @@ const (
 
 var CONTENT_TYPES_ALLOWED = []string{CONTENT_TYPE_BLOGPOST, CONTENT_TYPE_PAGE}
 
+var CONTENT_STATUSES_ALLOWED = []string{
+	wordpress.PostStatusPublish,
+	wordpress.PostStatusPrivate,
+	wordpress.PostStatusDraft,
+	wordpress.PostStatusPending,
+	"future",
+}
+
+// Validate checks the Wordpress configuration without contacting the Wordpress site. Required fields and enums are
+// declared by the schema annotations of the configuration and checked by the streams configuration validator
+func (cfg *WordpressConfig) Validate() []ConfigError {
+	errs := []ConfigError{}
+	if _, err := template.New("template").Funcs(sprig.FuncMap()).Parse(cfg.Data.Content); err != nil {
+		errs = append(errs, ConfigError{Field: "data.content", Message: fmt.Sprintf("invalid template. %s", err)})
+	}
+	return errs
+}
+
+func (cfg *WordpressConfig) Publisher() (Publisher, error) {
+	return NewWordpressPublisher(cfg)
+}
+
 func NewWordpressPublisher(cfg *WordpressConfig) (*Wordpress, error) {
 	proto := "http"
 	if cfg.TLS {
@@ -113,14 +157,23 @@ func (w *Wordpress) templatePage(vars interface{}) (string, error) {
 	return res.String(), err
 }
 
+// Publish creates a post or page for the broadcast, or updates the existing page when one is configured
 func (w *Wordpress) Publish(broadcast *youtube.LiveBroadcast, publishVars interface{}) error {
+	_, err := w.PublishRef(broadcast, publishVars, "")
+	return err
+}
+
+// PublishRef creates a post or page for the broadcast, or updates the post or page with the ID ref when there is one.
+// The ID of the created post or page is returned. A configured existing page is shared between broadcasts, so it is
+// updated instead and has no ref
+func (w *Wordpress) PublishRef(broadcast *youtube.LiveBroadcast, publishVars interface{}, ref string) (string, error) {
 	type Vars struct {
 		Broadcast *youtube.LiveBroadcast
 		ExtraVars interface{}
 	}
 
-	if !stringInSlice(defaultValue(w.data.Meta.Type, CONTENT_TYPE_PAGE, ""), CONTENT_TYPES_ALLOWED) {
-		return fmt.Errorf("invalid value for Wordpress content type. must be one of [%s]", strings.Join(CONTENT_TYPES_ALLOWED, ", "))
+	if !helper.StringInSlice(helper.DefaultValue(w.data.Meta.Type, CONTENT_TYPE_PAGE, ""), CONTENT_TYPES_ALLOWED) {
+		return ref, fmt.Errorf("invalid value for Wordpress content type. must be one of [%s]", strings.Join(CONTENT_TYPES_ALLOWED, ", "))
 	}
 
 	pageContent, err := w.templatePage(&Vars{
@@ -128,14 +181,14 @@ func (w *Wordpress) Publish(broadcast *youtube.LiveBroadcast, publishVars interf
 		ExtraVars: publishVars,
 	})
 	if err != nil {
-		return err
+		return ref, err
 	}
 
 	post := &wordpress.Post{
 		Password:      w.data.Meta.Password,
 		Slug:          w.data.Meta.Slug,
-		Status:        defaultValue(w.data.Meta.Status, wordpress.PostStatusPrivate, ""),
-		Type:          defaultValue(w.data.Meta.Type, CONTENT_TYPE_PAGE, ""),
+		Status:        helper.DefaultValue(w.data.Meta.Status, wordpress.PostStatusPrivate, ""),
+		Type:          helper.DefaultValue(w.data.Meta.Type, CONTENT_TYPE_PAGE, ""),
 		Title:         wordpress.Title{Raw: broadcast.Snippet.Title},
 		Content:       wordpress.Content{Raw: pageContent},
 		Author:        w.data.Meta.AuthorOverride,
@@ -145,9 +198,9 @@ func (w *Wordpress) Publish(broadcast *youtube.LiveBroadcast, publishVars interf
 	page := &wordpress.Page{
 		Password:      w.data.Meta.Password,
 		Slug:          w.data.Meta.Slug,
-		Status:        defaultValue(w.data.Meta.Status, wordpress.PostStatusPrivate, ""),
-		Type:          defaultValue(w.data.Meta.Type, CONTENT_TYPE_PAGE, ""),
-		Title:         wordpress.Title{Raw: defaultValue(w.data.Meta.TitleOverride, broadcast.Snippet.Title, "")},
+		Status:        helper.DefaultValue(w.data.Meta.Status, wordpress.PostStatusPrivate, ""),
+		Type:          helper.DefaultValue(w.data.Meta.Type, CONTENT_TYPE_PAGE, ""),
+		Title:         wordpress.Title{Raw: helper.DefaultValue(w.data.Meta.TitleOverride, broadcast.Snippet.Title, "")},
 		Content:       wordpress.Content{Raw: pageContent},
 		Author:        w.data.Meta.AuthorOverride,
 		CommentStatus: w.data.Meta.CommentStatus,
@@ -162,10 +215,29 @@ func (w *Wordpress) Publish(broadcast *youtube.LiveBroadcast, publishVars interf
 
 		if w.data.Meta.Type == CONTENT_TYPE_BLOGPOST {
 			_, _, _, err := w.client.Posts().Update(w.data.Meta.Id, post)
-			return err
+			return "", err
 		}
 		_, _, _, err := w.client.Pages().Update(w.data.Meta.Id, page)
-		return err
+		return "", err
+	}
+
+	// update what was published for the broadcast before
+	if ref != "" {
+		id, err := strconv.Atoi(ref)
+		if err != nil {
+			return ref, fmt.Errorf("invalid wordpress post ID %q. %w", ref, err)
+		}
+		logging.YLSLogger().Debug("updating published content for stream publish",
+			zap.String("content", pageContent),
+			zap.Int("id", id),
+		)
+
+		if w.data.Meta.Type == CONTENT_TYPE_BLOGPOST {
+			_, _, _, err = w.client.Posts().Update(id, post)
+		} else {
+			_, _, _, err = w.client.Pages().Update(id, page)
+		}
+		return ref, err
 	}
 
 	logging.YLSLogger().Debug("creating new post for stream publish",
@@ -173,9 +245,132 @@ func (w *Wordpress) Publish(broadcast *youtube.LiveBroadcast, publishVars interf
 		zap.String("content", pageContent),
 	)
 	if w.data.Meta.Type == CONTENT_TYPE_BLOGPOST {
-		_, _, _, err := w.client.Posts().Create(post)
+		created, _, _, err := w.client.Posts().Create(post)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(created.ID), nil
+	}
+	created, _, _, err := w.client.Pages().Create(page)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(created.ID), nil
+}
+
+// Unpublish removes what was published for the broadcast without a recorded ref (see UnpublishRef)
+func (w *Wordpress) Unpublish(broadcast *youtube.LiveBroadcast) error {
+	return w.UnpublishRef(broadcast, "")
+}
+
+// UnpublishRef moves the post or page with the ID ref to the trash. Without a ref, the content is looked up by its slug
+// or title and only trashed when exactly one post or page matches
+func (w *Wordpress) UnpublishRef(broadcast *youtube.LiveBroadcast, ref string) error {
+	contentType := helper.DefaultValue(w.data.Meta.Type, CONTENT_TYPE_PAGE, "")
+
+	// an existing page is shared between broadcasts, so it is only hidden as a draft rather than removed
+	if w.data.Meta.Id != 0 {
+		logging.YLSLogger().Debug("unpublishing existing page by reverting it to a draft",
+			zap.Int("existingPageID", w.data.Meta.Id),
+		)
+
+		if contentType == CONTENT_TYPE_BLOGPOST {
+			_, _, _, err := w.client.Posts().Update(w.data.Meta.Id, &wordpress.Post{Status: wordpress.PostStatusDraft})
+			return err
+		}
+		_, _, _, err := w.client.Pages().Update(w.data.Meta.Id, &wordpress.Page{Status: wordpress.PostStatusDraft})
+		return err
+	}
+
+	if ref != "" {
+		id, err := strconv.Atoi(ref)
+		if err != nil {
+			return fmt.Errorf("invalid wordpress post ID %q. %w", ref, err)
+		}
+		return w.trash(contentType, id)
+	}
+
+	ids, err := w.find(contentType, broadcast)
+	if err != nil {
 		return err
 	}
-	_, _, _, err = w.client.Pages().Create(page)
+	switch len(ids) {
+	case 0:
+		logging.YLSLogger().Warn("nothing to unpublish. no published content matched the broadcast",
+			zap.String("broadcastId", broadcast.Id),
+			zap.String("slug", w.data.Meta.Slug),
+		)
+		return nil
+	case 1:
+		logging.YLSLogger().Warn("no wordpress post was recorded for the broadcast. trashing the only content matching it",
+			zap.String("broadcastId", broadcast.Id),
+			zap.Int("id", ids[0]),
+		)
+		return w.trash(contentType, ids[0])
+	default:
+		logging.YLSLogger().Warn("no wordpress post was recorded for the broadcast and several posts match it. nothing was trashed",
+			zap.String("broadcastId", broadcast.Id),
+			zap.Ints("ids", ids),
+		)
+		return fmt.Errorf("unable to tell which of the %d %ss matching the broadcast to unpublish", len(ids), contentType)
+	}
+}
+
+// find returns the IDs of the content matching the configured slug or, without a slug, the exact title the broadcast
+// was published with
+func (w *Wordpress) find(contentType string, broadcast *youtube.LiveBroadcast) ([]int, error) {
+	title := helper.DefaultValue(w.data.Meta.TitleOverride, broadcast.Snippet.Title, "")
+	if contentType == CONTENT_TYPE_BLOGPOST {
+		title = broadcast.Snippet.Title
+	}
+
+	params := url.Values{}
+	params.Set("status", strings.Join([]string{
+		wordpress.PostStatusPublish,
+		wordpress.PostStatusPrivate,
+		wordpress.PostStatusDraft,
+		wordpress.PostStatusPending,
+	}, ","))
+	if w.data.Meta.Slug != "" {
+		params.Set("slug", w.data.Meta.Slug)
+	} else {
+		params.Set("search", title)
+	}
+
+	var ids []int
+	if contentType == CONTENT_TYPE_BLOGPOST {
+		posts, _, _, err := w.client.Posts().List(params.Encode())
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range posts {
+			if w.data.Meta.Slug != "" || p.Title.Raw == title || p.Title.Rendered == title {
+				ids = append(ids, p.ID)
+			}
+		}
+		return ids, nil
+	}
+
+	pages, _, _, err := w.client.Pages().List(params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pages {
+		if w.data.Meta.Slug != "" || p.Title.Raw == title || p.Title.Rendered == title {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids, nil
+}
+
+// trash moves the post or page with the given ID to the trash
+func (w *Wordpress) trash(contentType string, id int) error {
+	logging.YLSLogger().Debug("moving published content to the trash", zap.Int("id", id), zap.String("type", contentType))
+	var err error
+	if contentType == CONTENT_TYPE_BLOGPOST {
+		_, _, _, err = w.client.Posts().Delete(id, nil)
+	} else {
+		_, _, _, err = w.client.Pages().Delete(id, nil)
+	}
 	return err
 }
@@ -0,0 +1,218 @@
+package pub
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sogko/go-wordpress"
+	"google.golang.org/api/youtube/v3"
+)
+
+const testWordpressPagesPath = "/wp-json/wp/v2/pages"
+
+// fakeWordpress serves the pages of the Wordpress REST API, keeping the pages which have been created
+type fakeWordpress struct {
+	mu     sync.Mutex
+	nextId int
+	pages  map[int]*wordpress.Page
+}
+
+func newFakeWordpress(t *testing.T) (*fakeWordpress, *Wordpress) {
+	t.Helper()
+
+	f := &fakeWordpress{pages: map[int]*wordpress.Page{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWordpressPublisher(&WordpressConfig{
+		Host:     host,
+		Port:     port,
+		Username: "yls",
+		AppToken: "token",
+		Data:     WordpressData{Content: "{{ .Broadcast.Snippet.Title }} is live"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create wordpress publisher. %s", err)
+	}
+	return f, w
+}
+
+func (f *fakeWordpress) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/wp-json/wp/v2/users/me" {
+		json.NewEncoder(w).Encode(wordpress.User{ID: 1, Name: "yls"})
+		return
+	}
+
+	if r.URL.Path == testWordpressPagesPath {
+		if r.Method == http.MethodPost {
+			page := &wordpress.Page{}
+			json.NewDecoder(r.Body).Decode(page)
+			f.nextId++
+			page.ID = f.nextId
+			f.pages[page.ID] = page
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(page)
+			return
+		}
+
+		matches := []wordpress.Page{}
+		for id := 1; id <= f.nextId; id++ {
+			p, ok := f.pages[id]
+			if !ok || p.Status == "trash" {
+				continue
+			}
+			if slug := r.URL.Query().Get("slug"); slug != "" && p.Slug != slug {
+				continue
+			}
+			if search := r.URL.Query().Get("search"); search != "" && !strings.Contains(p.Title.Raw, search) {
+				continue
+			}
+			matches = append(matches, *p)
+		}
+		json.NewEncoder(w).Encode(matches)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, testWordpressPagesPath+"/"))
+	page, ok := f.pages[id]
+	if err != nil || !ok {
+		http.Error(w, `{"code": "rest_post_invalid_id"}`, http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.URL.Query().Get("_method") == "DELETE":
+		page.Status = "trash"
+	case r.Method == http.MethodPost:
+		update := &wordpress.Page{}
+		json.NewDecoder(r.Body).Decode(update)
+		update.ID = id
+		f.pages[id] = update
+		page = update
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func (f *fakeWordpress) page(t *testing.T, ref string) *wordpress.Page {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id, _ := strconv.Atoi(ref)
+	p, ok := f.pages[id]
+	if !ok {
+		t.Fatalf("expected page %q to exist", ref)
+	}
+	return p
+}
+
+func (f *fakeWordpress) trashed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, p := range f.pages {
+		if p.Status == "trash" {
+			n++
+		}
+	}
+	return n
+}
+
+func testWordpressBroadcast(title string) *youtube.LiveBroadcast {
+	return &youtube.LiveBroadcast{Id: "broadcast-1", Snippet: &youtube.LiveBroadcastSnippet{Title: title}}
+}
+
+func TestWordpressPublishRefUpdatesPublishedPage(t *testing.T) {
+	f, w := newFakeWordpress(t)
+
+	ref, err := w.PublishRef(testWordpressBroadcast("Sunday Service"), nil, "")
+	if err != nil {
+		t.Fatalf("expected the page to be created, got %s", err)
+	}
+	if ref == "" {
+		t.Fatal("expected the ID of the created page as ref")
+	}
+
+	updated, err := w.PublishRef(testWordpressBroadcast("Sunday Service (moved)"), nil, ref)
+	if err != nil {
+		t.Fatalf("expected the page to be updated, got %s", err)
+	}
+	if updated != ref {
+		t.Errorf("expected the ref %q to be kept, got %q", ref, updated)
+	}
+	if n := len(f.pages); n != 1 {
+		t.Errorf("expected 1 page, got %d", n)
+	}
+	if got := f.page(t, ref).Content.Raw; got != "Sunday Service (moved) is live" {
+		t.Errorf("expected the page content to be updated, got %q", got)
+	}
+}
+
+func TestWordpressUnpublishRefTrashesOnlyRef(t *testing.T) {
+	f, w := newFakeWordpress(t)
+	b := testWordpressBroadcast("Sunday Service")
+
+	first, err := w.PublishRef(b, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := w.PublishRef(b, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.UnpublishRef(b, first); err != nil {
+		t.Fatalf("expected the page to be trashed, got %s", err)
+	}
+	if got := f.page(t, first).Status; got != "trash" {
+		t.Errorf("expected page %s to be trashed, got status %q", first, got)
+	}
+	if got := f.page(t, second).Status; got == "trash" {
+		t.Errorf("expected page %s with the same title to be kept", second)
+	}
+}
+
+func TestWordpressUnpublishWithoutRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		pages   int
+		trashed int
+		wantErr bool
+	}{
+		{name: "no match", pages: 0, trashed: 0},
+		{name: "single match", pages: 1, trashed: 1},
+		{name: "several matches", pages: 2, trashed: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, w := newFakeWordpress(t)
+			b := testWordpressBroadcast("Sunday Service")
+			for i := 0; i < tt.pages; i++ {
+				if _, err := w.PublishRef(b, nil, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := w.Unpublish(b)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %t, got %v", tt.wantErr, err)
+			}
+			if n := f.trashed(); n != tt.trashed {
+				t.Errorf("expected %d trashed pages, got %d", tt.trashed, n)
+			}
+		})
+	}
+}
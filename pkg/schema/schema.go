@@ -0,0 +1,170 @@
+package schema
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const JSON_SCHEMA_DRAFT = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is the subset of JSON Schema used to describe YAML configuration
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *uint64                `json:"minimum,omitempty"`
+	Maximum              *uint64                `json:"maximum,omitempty"`
+	Examples             []interface{}          `json:"examples,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+}
+
+// Field annotates a single YAML field of a configuration type
+type Field struct {
+	Description string
+	Enum        []string
+	Required    bool
+	Examples    []interface{}
+}
+
+var (
+	mu          sync.RWMutex
+	annotations = map[reflect.Type]map[string]Field{}
+	types       = map[reflect.Type]*JSONSchema{}
+)
+
+// Annotate registers the annotations of the YAML fields (by YAML name) of a configuration struct type
+func Annotate(t reflect.Type, fields map[string]Field) {
+	mu.Lock()
+	defer mu.Unlock()
+	annotations[t] = fields
+}
+
+// RegisterType registers the schema of a type which decodes itself from YAML (such as a duration parsed from a string)
+func RegisterType(t reflect.Type, s *JSONSchema) {
+	mu.Lock()
+	defer mu.Unlock()
+	types[t] = s
+}
+
+// Lookup returns the annotation of a YAML field of a configuration struct type
+func Lookup(t reflect.Type, name string) (Field, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	f, ok := annotations[t][name]
+	return f, ok
+}
+
+// Required returns the YAML names of the required fields of a configuration struct type
+func Required(t reflect.Type) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	required := []string{}
+	for name := range YAMLFields(t) {
+		if annotations[t][name].Required {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	return required
+}
+
+// YAMLFields maps the YAML names of the fields of a struct type to their type, following the rules of yaml.v3
+func YAMLFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, ft := range YAMLFields(f.Type) {
+				fields[k] = ft
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// Generate builds the JSON Schema of a configuration type from its YAML fields and registered annotations
+func Generate(t reflect.Type) *JSONSchema {
+	mu.RLock()
+	defer mu.RUnlock()
+	return generate(t)
+}
+
+func generate(t reflect.Type) *JSONSchema {
+	if s, ok := types[t]; ok {
+		c := *s
+		return &c
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return generate(t.Elem())
+	case reflect.Struct:
+		s := &JSONSchema{
+			Type:                 "object",
+			Properties:           map[string]*JSONSchema{},
+			AdditionalProperties: false,
+		}
+		for name, ft := range YAMLFields(t) {
+			p := generate(ft)
+			if a, ok := annotations[t][name]; ok {
+				p.Description = a.Description
+				p.Enum = a.Enum
+				p.Examples = a.Examples
+				if a.Required {
+					s.Required = append(s.Required, name)
+				}
+			}
+			s.Properties[name] = p
+		}
+		sort.Strings(s.Required)
+		return s
+	case reflect.Map:
+		return &JSONSchema{
+			Type:                 "object",
+			AdditionalProperties: generate(t.Elem()),
+		}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{
+			Type:  "array",
+			Items: generate(t.Elem()),
+		}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min, max := uint64(0), uint64(1)<<t.Bits()-1
+		return &JSONSchema{Type: "integer", Minimum: &min, Maximum: &max}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	}
+
+	// interfaces accept any value
+	return &JSONSchema{}
+}
@@ -0,0 +1,358 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+const STATE_FILE_VERSION = 1
+
+const (
+	PUBLISH_STATUS_SUCCEEDED   = "succeeded"
+	PUBLISH_STATUS_FAILED      = "failed"
+	PUBLISH_STATUS_UNPUBLISHED = "unpublished"
+)
+
+const (
+	STEP_STATUS_SUCCEEDED = "succeeded"
+	STEP_STATUS_FAILED    = "failed"
+	STEP_STATUS_SKIPPED   = "skipped"
+)
+
+type PublisherResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Ref refers to what the publisher published, such as a message ID, for publishers which can update it later
+	Ref       string    `json:"ref,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// BroadcastRecord is the persisted record of a single LiveBroadcast created by YLS
+type BroadcastRecord struct {
+	StreamName     string            `json:"streamName"`
+	BroadcastID    string            `json:"broadcastId"`
+	OccurrenceKey  string            `json:"occurrenceKey,omitempty"`
+	Occurrence     int               `json:"occurrence,omitempty"`
+	Title          string            `json:"title"`
+	ScheduledStart time.Time         `json:"scheduledStart"`
+	Privacy        string            `json:"privacy"`
+	Publishers     []PublisherResult `json:"publishers,omitempty"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	UpdatedAt      time.Time         `json:"updatedAt"`
+	CancelledAt    *time.Time        `json:"cancelledAt,omitempty"`
+}
+
+// StepResult is the outcome of a single step of a job, such as creating the broadcast or publishing it
+type StepResult struct {
+	Step   string `json:"step"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// JobResult is the outcome of a job run for a stream. The result of the most recent run of each stream is persisted
+type JobResult struct {
+	StreamName    string       `json:"streamName"`
+	OccurrenceKey string       `json:"occurrenceKey,omitempty"`
+	BroadcastID   string       `json:"broadcastId,omitempty"`
+	DryRun        bool         `json:"dryRun,omitempty"`
+	Steps         []StepResult `json:"steps"`
+	StartedAt     time.Time    `json:"startedAt"`
+	FinishedAt    time.Time    `json:"finishedAt"`
+}
+
+// Step records the outcome of a step. A nil error is a success
+func (r *JobResult) Step(step string, err error) {
+	res := StepResult{Step: step, Status: STEP_STATUS_SUCCEEDED}
+	if err != nil {
+		res.Status = STEP_STATUS_FAILED
+		res.Error = err.Error()
+	}
+	r.Steps = append(r.Steps, res)
+}
+
+// Skip records a step that did not apply to the job
+func (r *JobResult) Skip(step string) {
+	r.Steps = append(r.Steps, StepResult{Step: step, Status: STEP_STATUS_SKIPPED})
+}
+
+// Failed reports whether any step of the job failed
+func (r *JobResult) Failed() bool {
+	return len(r.FailedSteps()) > 0
+}
+
+// FailedSteps returns the names of the steps that failed
+func (r *JobResult) FailedSteps() []string {
+	failed := []string{}
+	for _, s := range r.Steps {
+		if s.Status == STEP_STATUS_FAILED {
+			failed = append(failed, s.Step)
+		}
+	}
+	return failed
+}
+
+func (r *JobResult) clone() *JobResult {
+	c := *r
+	c.Steps = append([]StepResult{}, r.Steps...)
+	return &c
+}
+
+// SetPublisher records the latest result of a publisher for the broadcast, replacing its previous result
+func (r *BroadcastRecord) SetPublisher(result PublisherResult) {
+	publishers := r.Publishers[:0]
+	for _, p := range r.Publishers {
+		if p.Name != result.Name {
+			publishers = append(publishers, p)
+		}
+	}
+	r.Publishers = append(publishers, result)
+}
+
+// PublisherRef returns the ref of what the named publisher published for the broadcast. Unpublished results have no ref
+func (r *BroadcastRecord) PublisherRef(name string) string {
+	for _, p := range r.Publishers {
+		if p.Name == name {
+			return p.Ref
+		}
+	}
+	return ""
+}
+
+// merge keeps what another process recorded for the broadcast since r was read: its creation time, its cancellation and
+// the results of publishers which are more recent than those of r
+func (r *BroadcastRecord) merge(existing *BroadcastRecord) {
+	r.CreatedAt = existing.CreatedAt
+	if r.CancelledAt == nil {
+		r.CancelledAt = existing.CancelledAt
+	}
+	for _, p := range existing.Publishers {
+		if current, ok := r.publisher(p.Name); !ok || current.Timestamp.Before(p.Timestamp) {
+			r.SetPublisher(p)
+		}
+	}
+}
+
+func (r *BroadcastRecord) publisher(name string) (PublisherResult, bool) {
+	for _, p := range r.Publishers {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PublisherResult{}, false
+}
+
+func (r *BroadcastRecord) clone() *BroadcastRecord {
+	c := *r
+	c.Publishers = append([]PublisherResult{}, r.Publishers...)
+	return &c
+}
+
+type stateFile struct {
+	Version    int                `json:"version"`
+	Broadcasts []*BroadcastRecord `json:"broadcasts"`
+	Jobs       []*JobResult       `json:"jobs,omitempty"`
+}
+
+// Store is a JSON file backed record of every broadcast that YLS has created. It is safe for concurrent use.
+// A nil *Store is valid and records nothing, which is used when state tracking is disabled
+type Store struct {
+	path string
+	mu   sync.Mutex
+	data stateFile
+}
+
+// Open loads the state store from the provided path. The file is created on the first write if it does not exist yet
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load replaces the in-memory state with the state file on disk
+func (s *Store) load() error {
+	s.data = stateFile{Version: STATE_FILE_VERSION}
+
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		logging.YLSLogger().Debug("state file does not exist yet. starting with empty state", zap.String("file", s.path))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &s.data)
+}
+
+// update applies a change to the latest state on disk while holding the lock of the state file, then saves it. Each
+// process (such as the start daemon and the cancel command) keeps its own Store, so the state is always re-read first
+// to keep the changes made by the other processes since
+func (s *Store) update(change func()) error {
+	unlock, err := lockFile(s.path+".lock", true)
+	if err != nil {
+		return fmt.Errorf("unable to lock state file. %w", err)
+	}
+	defer unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	change()
+	return s.save()
+}
+
+// refresh re-reads the state file so that the changes made by other processes are seen. A state file which cannot be
+// read is logged and the state already in memory is kept
+func (s *Store) refresh() {
+	unlock, err := lockFile(s.path+".lock", false)
+	if err != nil {
+		logging.YLSLogger().Warn("unable to lock state file. using the state already loaded", zap.String("file", s.path), zap.Error(err))
+		return
+	}
+	defer unlock()
+
+	data := s.data
+	if err := s.load(); err != nil {
+		logging.YLSLogger().Warn("unable to read state file. using the state already loaded", zap.String("file", s.path), zap.Error(err))
+		s.data = data
+	}
+}
+
+// Put creates or updates the record for a broadcast (by BroadcastID) and persists the store to disk. A record updated
+// by another process in the meantime is merged with r (see BroadcastRecord.merge)
+func (s *Store) Put(r *BroadcastRecord) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(func() {
+		r.UpdatedAt = time.Now()
+		for i, existing := range s.data.Broadcasts {
+			if existing.BroadcastID == r.BroadcastID {
+				r.merge(existing)
+				s.data.Broadcasts[i] = r.clone()
+				return
+			}
+		}
+
+		if r.CreatedAt.IsZero() {
+			r.CreatedAt = r.UpdatedAt
+		}
+		s.data.Broadcasts = append(s.data.Broadcasts, r.clone())
+	})
+}
+
+// PutJob records the result of the most recent job run for a stream and persists the store to disk
+func (s *Store) PutJob(r *JobResult) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.update(func() {
+		for i, existing := range s.data.Jobs {
+			if existing.StreamName == r.StreamName {
+				s.data.Jobs[i] = r.clone()
+				return
+			}
+		}
+		s.data.Jobs = append(s.data.Jobs, r.clone())
+	})
+}
+
+// Job returns a copy of the result of the most recent job run for the named stream
+func (s *Store) Job(streamName string) (*JobResult, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+
+	for _, r := range s.data.Jobs {
+		if r.StreamName == streamName {
+			return r.clone(), true
+		}
+	}
+	return nil, false
+}
+
+// Get returns a copy of the record for the broadcast with the given ID
+func (s *Store) Get(broadcastId string) (*BroadcastRecord, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+
+	for _, r := range s.data.Broadcasts {
+		if r.BroadcastID == broadcastId {
+			return r.clone(), true
+		}
+	}
+	return nil, false
+}
+
+// List returns copies of all records for the named stream (or every stream when name is empty) ordered by scheduled start
+func (s *Store) List(streamName string) []*BroadcastRecord {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+
+	res := []*BroadcastRecord{}
+	for _, r := range s.data.Broadcasts {
+		if streamName == "" || r.StreamName == streamName {
+			res = append(res, r.clone())
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].ScheduledStart.Before(res[j].ScheduledStart)
+	})
+	return res
+}
+
+// save atomically writes the state to disk by writing to a temporary file and renaming it over the existing file
+func (s *Store) save() error {
+	b, err := json.MarshalIndent(&s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetPublisherKeepsLatestResult(t *testing.T) {
+	r := &BroadcastRecord{BroadcastID: "broadcast-1"}
+	r.SetPublisher(PublisherResult{Name: "wordpress", Status: PUBLISH_STATUS_FAILED, Error: "timeout"})
+	r.SetPublisher(PublisherResult{Name: "slack", Status: PUBLISH_STATUS_SUCCEEDED})
+	r.SetPublisher(PublisherResult{Name: "wordpress", Status: PUBLISH_STATUS_SUCCEEDED})
+
+	if len(r.Publishers) != 2 {
+		t.Fatalf("expected 1 result per publisher, got %+v", r.Publishers)
+	}
+	if p := r.Publishers[1]; p.Name != "wordpress" || p.Status != PUBLISH_STATUS_SUCCEEDED || p.Error != "" {
+		t.Errorf("expected the latest result of the wordpress publisher, got %+v", p)
+	}
+}
+
+func TestPublisherRef(t *testing.T) {
+	r := &BroadcastRecord{BroadcastID: "broadcast-1"}
+	r.SetPublisher(PublisherResult{Name: "discord", Status: PUBLISH_STATUS_SUCCEEDED, Ref: "message-1"})
+	r.SetPublisher(PublisherResult{Name: "discord", Status: PUBLISH_STATUS_SUCCEEDED, Ref: "message-2"})
+	if ref := r.PublisherRef("discord"); ref != "message-2" {
+		t.Errorf("expected the latest ref, got %q", ref)
+	}
+
+	r.SetPublisher(PublisherResult{Name: "discord", Status: PUBLISH_STATUS_UNPUBLISHED})
+	if ref := r.PublisherRef("discord"); ref != "" {
+		t.Errorf("expected no ref once unpublished, got %q", ref)
+	}
+	if ref := r.PublisherRef("wordpress"); ref != "" {
+		t.Errorf("expected no ref for an unknown publisher, got %q", ref)
+	}
+}
+
+func openStore(t *testing.T, path string) *Store {
+	t.Helper()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open state store. %s", err)
+	}
+	return s
+}
+
+func TestStoresSharingAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	daemon := openStore(t, path)
+	cli := openStore(t, path)
+
+	start := time.Date(2023, 3, 5, 14, 0, 0, 0, time.UTC)
+	if err := daemon.Put(&BroadcastRecord{StreamName: "sunday-service", BroadcastID: "broadcast-1", ScheduledStart: start}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cancel command cancels the broadcast the daemon created...
+	rec, ok := cli.Get("broadcast-1")
+	if !ok {
+		t.Fatal("expected the broadcast created by the other store to be seen")
+	}
+	cancelledAt := start.Add(-time.Hour)
+	rec.CancelledAt = &cancelledAt
+	rec.SetPublisher(PublisherResult{Name: "discord", Status: PUBLISH_STATUS_UNPUBLISHED, Timestamp: cancelledAt})
+	if err := cli.Put(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Put(&BroadcastRecord{StreamName: "midweek", BroadcastID: "broadcast-2", ScheduledStart: start}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ...while the daemon still holds the record it read before, and records the job of another stream
+	stale := &BroadcastRecord{StreamName: "sunday-service", BroadcastID: "broadcast-1", ScheduledStart: start}
+	stale.SetPublisher(PublisherResult{Name: "slack", Status: PUBLISH_STATUS_SUCCEEDED, Timestamp: cancelledAt})
+	if err := daemon.Put(stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.PutJob(&JobResult{StreamName: "sunday-service", BroadcastID: "broadcast-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.PutJob(&JobResult{StreamName: "midweek", BroadcastID: "broadcast-2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openStore(t, path)
+	got, ok := reopened.Get("broadcast-1")
+	if !ok {
+		t.Fatal("expected broadcast-1 to be kept")
+	}
+	if got.CancelledAt == nil || !got.CancelledAt.Equal(cancelledAt) {
+		t.Errorf("expected the cancellation to be kept, got %v", got.CancelledAt)
+	}
+	if len(got.Publishers) != 2 {
+		t.Errorf("expected the results of both publishers to be kept, got %+v", got.Publishers)
+	}
+	if _, ok := reopened.Get("broadcast-2"); !ok {
+		t.Error("expected broadcast-2 to be kept")
+	}
+	for _, name := range []string{"sunday-service", "midweek"} {
+		if _, ok := reopened.Job(name); !ok {
+			t.Errorf("expected the job of %s to be kept", name)
+		}
+	}
+}
+
+func TestStoresSharingAFileConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	stores := []*Store{openStore(t, path), openStore(t, path), openStore(t, path)}
+
+	const perStore = 20
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		wg.Add(1)
+		go func(i int, s *Store) {
+			defer wg.Done()
+			for j := 0; j < perStore; j++ {
+				if err := s.Put(&BroadcastRecord{BroadcastID: fmt.Sprintf("broadcast-%d-%d", i, j)}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	if n := len(openStore(t, path).List("")); n != len(stores)*perStore {
+		t.Errorf("expected %d broadcasts, got %d", len(stores)*perStore, n)
+	}
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// BroadcastBackend is the narrow set of YouTube Data API operations used by the StreamUploadClient. The Google
+// implementation is used by default, but any implementation (such as the in-memory FakeBackend) may be provided
+// through StreamUploaderConfig.Backend
+type BroadcastBackend interface {
+	ListBroadcasts(status string) ([]*youtube.LiveBroadcast, error)
+	InsertBroadcast(parts []string, b *youtube.LiveBroadcast) (*youtube.LiveBroadcast, error)
+	UpdateBroadcast(parts []string, b *youtube.LiveBroadcast) (*youtube.LiveBroadcast, error)
+	BindBroadcast(broadcastId, liveStreamId string) (*youtube.LiveBroadcast, error)
+	DeleteBroadcast(broadcastId string) error
+	TransitionBroadcast(broadcastId, status string) (*youtube.LiveBroadcast, error)
+	ListLiveStreams() ([]*youtube.LiveStream, error)
+	InsertLiveStream(parts []string, ls *youtube.LiveStream) (*youtube.LiveStream, error)
+	SetThumbnail(videoId string, media io.Reader) (*youtube.ThumbnailSetResponse, error)
+}
+
+const (
+	BROADCAST_STATUS_UPCOMING  = "upcoming"
+	BROADCAST_STATUS_ACTIVE    = "active"
+	BROADCAST_STATUS_COMPLETED = "completed"
+	BROADCAST_STATUS_ALL       = "all"
+)
+
+var BROADCAST_STATUSES_ALLOWED = []string{BROADCAST_STATUS_UPCOMING, BROADCAST_STATUS_ACTIVE, BROADCAST_STATUS_COMPLETED, BROADCAST_STATUS_ALL}
+
+type youtubeBackend struct {
+	ctx context.Context
+	svc *youtube.Service
+}
+
+// NewYoutubeBackend creates a BroadcastBackend which calls the YouTube Data API using the provided service
+func NewYoutubeBackend(ctx context.Context, svc *youtube.Service) BroadcastBackend {
+	return &youtubeBackend{
+		ctx: ctx,
+		svc: svc,
+	}
+}
+
+func (y *youtubeBackend) ListBroadcasts(status string) ([]*youtube.LiveBroadcast, error) {
+	var items []*youtube.LiveBroadcast
+	err := y.svc.LiveBroadcasts.List([]string{"id", "snippet", "status", "contentDetails"}).BroadcastStatus(status).BroadcastType("all").MaxResults(50).Pages(y.ctx, func(r *youtube.LiveBroadcastListResponse) error {
+		items = append(items, r.Items...)
+		return nil
+	})
+	return items, err
+}
+
+func (y *youtubeBackend) InsertBroadcast(parts []string, b *youtube.LiveBroadcast) (*youtube.LiveBroadcast, error) {
+	return y.svc.LiveBroadcasts.Insert(parts, b).Context(y.ctx).Do()
+}
+
+func (y *youtubeBackend) UpdateBroadcast(parts []string, b *youtube.LiveBroadcast) (*youtube.LiveBroadcast, error) {
+	return y.svc.LiveBroadcasts.Update(parts, b).Context(y.ctx).Do()
+}
+
+func (y *youtubeBackend) BindBroadcast(broadcastId, liveStreamId string) (*youtube.LiveBroadcast, error) {
+	return y.svc.LiveBroadcasts.Bind(broadcastId, []string{"id", "contentDetails"}).StreamId(liveStreamId).Context(y.ctx).Do()
+}
+
+func (y *youtubeBackend) DeleteBroadcast(broadcastId string) error {
+	return y.svc.LiveBroadcasts.Delete(broadcastId).Context(y.ctx).Do()
+}
+
+func (y *youtubeBackend) TransitionBroadcast(broadcastId, status string) (*youtube.LiveBroadcast, error) {
+	return y.svc.LiveBroadcasts.Transition(status, broadcastId, []string{"id", "snippet", "status", "contentDetails"}).Context(y.ctx).Do()
+}
+
+func (y *youtubeBackend) ListLiveStreams() ([]*youtube.LiveStream, error) {
+	var items []*youtube.LiveStream
+	err := y.svc.LiveStreams.List([]string{"id", "snippet", "cdn", "contentDetails"}).Mine(true).MaxResults(50).Pages(y.ctx, func(r *youtube.LiveStreamListResponse) error {
+		items = append(items, r.Items...)
+		return nil
+	})
+	return items, err
+}
+
+func (y *youtubeBackend) InsertLiveStream(parts []string, ls *youtube.LiveStream) (*youtube.LiveStream, error) {
+	return y.svc.LiveStreams.Insert(parts, ls).Context(y.ctx).Do()
+}
+
+func (y *youtubeBackend) SetThumbnail(videoId string, media io.Reader) (*youtube.ThumbnailSetResponse, error) {
+	return y.svc.Thumbnails.Set(videoId).Media(media).Context(y.ctx).Do()
+}
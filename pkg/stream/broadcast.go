@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+	"sykesdev.ca/yls/pkg/helper"
+)
+
+// BroadcastSummary is a flattened view of a LiveBroadcast suitable for display
+type BroadcastSummary struct {
+	ID             string `json:"id" yaml:"id"`
+	StreamName     string `json:"streamName,omitempty" yaml:"streamName,omitempty"`
+	Title          string `json:"title" yaml:"title"`
+	Status         string `json:"status" yaml:"status"`
+	ScheduledStart string `json:"scheduledStart" yaml:"scheduledStart"`
+	Privacy        string `json:"privacy" yaml:"privacy"`
+	BoundStreamKey string `json:"boundStreamKey,omitempty" yaml:"boundStreamKey,omitempty"`
+	ShareableLink  string `json:"shareableLink" yaml:"shareableLink"`
+	EmbedableLink  string `json:"embedableLink" yaml:"embedableLink"`
+}
+
+func ShareableLink(broadcastId string) string {
+	return fmt.Sprintf("https://youtube.com/live/%s?feature=share", broadcastId)
+}
+
+func EmbedableLink(broadcastId string) string {
+	return fmt.Sprintf("https://youtube.com/embed/%s", broadcastId)
+}
+
+// streamNameOf determines which configured stream a broadcast belongs to using its occurrence tag or, for broadcasts
+// that were not tagged, the state store
+func (u *StreamUploadClient) streamNameOf(broadcastId, occurrenceKey string) string {
+	if i := strings.LastIndex(occurrenceKey, "@"); i > 0 {
+		return occurrenceKey[:i]
+	}
+	if rec, ok := u.state.Get(broadcastId); ok {
+		return rec.StreamName
+	}
+	return ""
+}
+
+// List summarizes the broadcasts of the authenticated channel with the given status (one of BROADCAST_STATUSES_ALLOWED).
+// When streamName is not empty, only broadcasts created for that stream are returned
+func (u *StreamUploadClient) List(status, streamName string) ([]*BroadcastSummary, error) {
+	if !helper.StringInSlice(status, BROADCAST_STATUSES_ALLOWED) {
+		return nil, fmt.Errorf("invalid broadcast status %q. must be one of [%s]", status, strings.Join(BROADCAST_STATUSES_ALLOWED, ", "))
+	}
+
+	broadcasts, err := u.backend.ListBroadcasts(status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list broadcasts. %w", err)
+	}
+
+	liveStreams, err := u.backend.ListLiveStreams()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list live streams. %w", err)
+	}
+	streamKeys := make(map[string]string, len(liveStreams))
+	for _, ls := range liveStreams {
+		if ls.Cdn != nil && ls.Cdn.IngestionInfo != nil {
+			streamKeys[ls.Id] = ls.Cdn.IngestionInfo.StreamName
+		}
+	}
+
+	res := []*BroadcastSummary{}
+	for _, b := range broadcasts {
+		summary := u.summarize(b, streamKeys)
+		if streamName != "" && summary.StreamName != streamName {
+			continue
+		}
+		res = append(res, summary)
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].ScheduledStart < res[j].ScheduledStart
+	})
+	return res, nil
+}
+
+// summarize flattens a broadcast into a BroadcastSummary. Parts missing from the broadcast are left empty. streamKeys
+// maps the IDs of live streams to their stream keys and may be nil
+func (u *StreamUploadClient) summarize(b *youtube.LiveBroadcast, streamKeys map[string]string) *BroadcastSummary {
+	key, _ := OccurrenceKeyOf(b)
+	summary := &BroadcastSummary{
+		ID:            b.Id,
+		StreamName:    u.streamNameOf(b.Id, key),
+		ShareableLink: ShareableLink(b.Id),
+		EmbedableLink: EmbedableLink(b.Id),
+	}
+	if b.Snippet != nil {
+		summary.Title = b.Snippet.Title
+		summary.ScheduledStart = b.Snippet.ScheduledStartTime
+	}
+	if b.Status != nil {
+		summary.Status = b.Status.LifeCycleStatus
+		summary.Privacy = b.Status.PrivacyStatus
+	}
+	if b.ContentDetails != nil {
+		summary.BoundStreamKey = streamKeys[b.ContentDetails.BoundStreamId]
+	}
+	return summary
+}
@@ -0,0 +1,159 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/youtube/v3"
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/logging"
+	"sykesdev.ca/yls/pkg/pub"
+	"sykesdev.ca/yls/pkg/state"
+)
+
+// CancelFilter selects which upcoming broadcasts are cancelled. All the specified criteria must match
+type CancelFilter struct {
+	BroadcastIDs []string
+	StreamName   string
+	// From and To bound the scheduled start of the broadcasts (inclusive). Zero values are unbounded
+	From time.Time
+	To   time.Time
+}
+
+func (f *CancelFilter) empty() bool {
+	return len(f.BroadcastIDs) == 0 && f.StreamName == "" && f.From.IsZero() && f.To.IsZero()
+}
+
+func (f *CancelFilter) matches(b *BroadcastSummary) bool {
+	if len(f.BroadcastIDs) > 0 && !helper.StringInSlice(b.ID, f.BroadcastIDs) {
+		return false
+	}
+	if f.StreamName != "" && f.StreamName != b.StreamName {
+		return false
+	}
+	if f.From.IsZero() && f.To.IsZero() {
+		return true
+	}
+
+	start, err := time.Parse(time.RFC3339, b.ScheduledStart)
+	if err != nil {
+		return false
+	}
+	return (f.From.IsZero() || !start.Before(f.From)) && (f.To.IsZero() || !start.After(f.To))
+}
+
+// Cancel deletes the upcoming broadcasts selected by the filter. When unpublish is true, the publisher configured for
+// the stream (looked up in streams) that created each broadcast is asked to remove whatever it published.
+// The summaries of the selected broadcasts are returned, even in dry-run mode
+func (u *StreamUploadClient) Cancel(filter *CancelFilter, streams *StreamList, unpublish bool) ([]*BroadcastSummary, error) {
+	if filter.empty() {
+		return nil, errors.New("refusing to cancel every upcoming broadcast. specify broadcast IDs, a stream name or a date range")
+	}
+
+	upcoming, err := u.backend.ListBroadcasts(BROADCAST_STATUS_UPCOMING)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upcoming broadcasts. %w", err)
+	}
+
+	var errs []error
+	cancelled := []*BroadcastSummary{}
+	for _, b := range upcoming {
+		summary := u.summarize(b, nil)
+		if !filter.matches(summary) {
+			continue
+		}
+		streamName := summary.StreamName
+
+		var s *Stream
+		if streams != nil {
+			s = streams.Find(streamName)
+		}
+
+		if u.dryRun {
+			logging.YLSLogger().Info("would have deleted LiveBroadcast resource, but is dry-run",
+				zap.String("broadcastId", b.Id),
+				zap.String("streamName", streamName),
+				zap.String("title", summary.Title),
+				zap.String("scheduledStart", summary.ScheduledStart),
+				zap.Bool("unpublish", unpublish && s != nil && len(s.Publishers) > 0),
+			)
+			cancelled = append(cancelled, summary)
+			continue
+		}
+
+		if err := u.backend.DeleteBroadcast(b.Id); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete broadcast %s. %w", b.Id, err))
+			continue
+		}
+		logging.YLSLogger().Info("deleted live broadcast",
+			zap.String("broadcastId", b.Id),
+			zap.String("streamName", streamName),
+			zap.String("title", summary.Title),
+		)
+		cancelled = append(cancelled, summary)
+
+		if rec, ok := u.state.Get(b.Id); ok {
+			now := time.Now()
+			rec.CancelledAt = &now
+			u.record(rec)
+		}
+
+		if !unpublish {
+			continue
+		}
+		if s == nil || len(s.Publishers) == 0 {
+			logging.YLSLogger().Warn("no publisher config found for the stream of the cancelled broadcast. skipping unpublish",
+				zap.String("broadcastId", b.Id),
+				zap.String("streamName", streamName),
+			)
+			continue
+		}
+		if err := u.unpublish(s, b); err != nil {
+			errs = append(errs, fmt.Errorf("failed to unpublish broadcast %s. %w", b.Id, err))
+		}
+	}
+
+	return cancelled, joinErrors(errs)
+}
+
+// unpublish removes what every publisher of the stream published for the broadcast
+func (u *StreamUploadClient) unpublish(s *Stream, b *youtube.LiveBroadcast) error {
+	rec, ok := u.state.Get(b.Id)
+
+	errs := []error{}
+	results := []state.PublisherResult{}
+	for _, cfg := range s.Publishers.On(pub.PUBLISH_ON_SUCCESS) {
+		p, err := cfg.GetPublisher()
+		if err == nil {
+			if rp, isRef := p.(pub.RefPublisher); isRef && ok {
+				err = rp.UnpublishRef(b, rec.PublisherRef(cfg.String()))
+			} else {
+				err = p.Unpublish(b)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("publisher %s. %w", cfg, err))
+			continue
+		}
+
+		logging.YLSLogger().Info("unpublished cancelled broadcast using configured publisher",
+			zap.String("broadcastId", b.Id),
+			zap.Stringer("publisher", cfg),
+		)
+		results = append(results, state.PublisherResult{
+			Name:      cfg.String(),
+			Status:    state.PUBLISH_STATUS_UNPUBLISHED,
+			Timestamp: time.Now(),
+		})
+	}
+
+	if ok && len(results) > 0 {
+		for _, result := range results {
+			rec.SetPublisher(result)
+		}
+		u.record(rec)
+	}
+	return joinErrors(errs)
+}
@@ -0,0 +1,37 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"sykesdev.ca/yls/pkg/state"
+)
+
+func TestCancelBroadcastWithoutSnippet(t *testing.T) {
+	u, fake, st := newTestUploader(t)
+	b := insertTestBroadcast(t, fake, "ready", time.Now().Add(time.Hour), time.Time{})
+	b.Snippet = nil
+	if _, err := fake.UpdateBroadcast([]string{"snippet"}, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Put(&state.BroadcastRecord{StreamName: "sunday-service", BroadcastID: b.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	// without a scheduled start, the broadcast cannot be within a date range
+	cancelled, err := u.Cancel(&CancelFilter{StreamName: "sunday-service", From: time.Now()}, nil, false)
+	if err != nil || len(cancelled) != 0 {
+		t.Fatalf("expected no broadcast to be cancelled, got %v, %v", cancelled, err)
+	}
+
+	cancelled, err = u.Cancel(&CancelFilter{StreamName: "sunday-service"}, nil, false)
+	if err != nil {
+		t.Fatalf("failed to cancel broadcast. %s", err)
+	}
+	if len(cancelled) != 1 || cancelled[0].ID != b.Id || cancelled[0].Status != "ready" {
+		t.Errorf("expected the broadcast to be cancelled, got %+v", cancelled)
+	}
+	if fake.Broadcast(b.Id) != nil {
+		t.Error("expected the broadcast to be deleted")
+	}
+}
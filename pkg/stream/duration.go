@@ -0,0 +1,62 @@
+package stream
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dayPattern matches a leading number of days in a duration such as "7d" or "1d12h"
+var dayPattern = regexp.MustCompile(`^(\d+)d`)
+
+// Duration is a time.Duration that is configured in YAML using Go duration strings extended with a day ("d") unit
+type Duration struct {
+	time.Duration
+}
+
+// ParseDuration parses durations such as "7d", "1d12h" or "90m"
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	var days time.Duration
+	if m := dayPattern.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, err
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		s = strings.TrimPrefix(s, m[0])
+		if s == "" {
+			return days, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q. %w", s, err)
+	}
+	return days + d, nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+
+	// a TypeError allows the decoder to continue and report the remaining problems in the file
+	parsed, err := ParseDuration(s)
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %s", value.Line, err)}}
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
@@ -0,0 +1,339 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"sykesdev.ca/yls/pkg/helper"
+)
+
+// FakeBackend is a stateful, in-memory BroadcastBackend which can be used to exercise the StreamUploadClient without
+// access to YouTube. It is safe for concurrent use.
+type FakeBackend struct {
+	mu         sync.Mutex
+	nextId     int
+	broadcasts map[string]*youtube.LiveBroadcast
+	streams    map[string]*youtube.LiveStream
+	thumbnails map[string][][]byte
+	failures   map[string]error
+}
+
+const (
+	FAKE_OP_LIST_BROADCASTS   = "ListBroadcasts"
+	FAKE_OP_INSERT_BROADCAST  = "InsertBroadcast"
+	FAKE_OP_UPDATE_BROADCAST  = "UpdateBroadcast"
+	FAKE_OP_BIND_BROADCAST    = "BindBroadcast"
+	FAKE_OP_DELETE_BROADCAST  = "DeleteBroadcast"
+	FAKE_OP_TRANSITION        = "TransitionBroadcast"
+	FAKE_OP_LIST_LIVESTREAMS  = "ListLiveStreams"
+	FAKE_OP_INSERT_LIVESTREAM = "InsertLiveStream"
+	FAKE_OP_SET_THUMBNAIL     = "SetThumbnail"
+)
+
+// fakeLifeCycleStatuses maps the broadcastStatus filter used when listing broadcasts to the lifecycle statuses it matches
+var fakeLifeCycleStatuses = map[string][]string{
+	BROADCAST_STATUS_UPCOMING:  {"created", "ready", "testStarting", "testing"},
+	BROADCAST_STATUS_ACTIVE:    {"liveStarting", "live"},
+	BROADCAST_STATUS_COMPLETED: {"complete", "revoked"},
+}
+
+const FAKE_THUMBNAIL_URL_PATTERN = "https://fake.youtube.local/vi/%s/%d.jpg"
+
+func NewFakeBackend() *FakeBackend {
+	return &FakeBackend{
+		broadcasts: map[string]*youtube.LiveBroadcast{},
+		streams:    map[string]*youtube.LiveStream{},
+		thumbnails: map[string][][]byte{},
+		failures:   map[string]error{},
+	}
+}
+
+// FailNext causes the next call to the named operation (one of the FAKE_OP_* constants) to return err
+func (f *FakeBackend) FailNext(op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[op] = err
+}
+
+// AddLiveStream seeds the fake with an existing LiveStream, as if it had been created in YouTube Studio
+func (f *FakeBackend) AddLiveStream(ls *youtube.LiveStream) *youtube.LiveStream {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addLiveStream(ls)
+}
+
+// Broadcasts returns copies of all the broadcasts currently known to the fake
+func (f *FakeBackend) Broadcasts() []*youtube.LiveBroadcast {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res := make([]*youtube.LiveBroadcast, 0, len(f.broadcasts))
+	for _, b := range f.broadcasts {
+		res = append(res, copyBroadcast(b))
+	}
+	return res
+}
+
+// Broadcast returns a copy of the broadcast with the given ID, or nil if it does not exist
+func (f *FakeBackend) Broadcast(id string) *youtube.LiveBroadcast {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return copyBroadcast(f.broadcasts[id])
+}
+
+// Thumbnails returns the raw thumbnail images uploaded for the video with the given ID in upload order
+func (f *FakeBackend) Thumbnails(videoId string) [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]byte{}, f.thumbnails[videoId]...)
+}
+
+func (f *FakeBackend) ListBroadcasts(status string) ([]*youtube.LiveBroadcast, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_LIST_BROADCASTS); err != nil {
+		return nil, err
+	}
+
+	res := []*youtube.LiveBroadcast{}
+	for _, b := range f.broadcasts {
+		if status == BROADCAST_STATUS_ALL || helper.StringInSlice(b.Status.LifeCycleStatus, fakeLifeCycleStatuses[status]) {
+			res = append(res, copyBroadcast(b))
+		}
+	}
+	return res, nil
+}
+
+func (f *FakeBackend) InsertBroadcast(parts []string, b *youtube.LiveBroadcast) (*youtube.LiveBroadcast, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_INSERT_BROADCAST); err != nil {
+		return nil, err
+	}
+
+	nb := copyBroadcast(b)
+	nb.Id = f.newId("broadcast")
+	nb.Kind = "youtube#liveBroadcast"
+	if nb.Snippet == nil {
+		nb.Snippet = &youtube.LiveBroadcastSnippet{}
+	}
+	if nb.Status == nil {
+		nb.Status = &youtube.LiveBroadcastStatus{}
+	}
+	if nb.ContentDetails == nil {
+		nb.ContentDetails = &youtube.LiveBroadcastContentDetails{}
+	}
+	nb.Status.LifeCycleStatus = "created"
+	nb.Status.RecordingStatus = "notRecording"
+	f.broadcasts[nb.Id] = nb
+
+	return copyBroadcast(nb), nil
+}
+
+func (f *FakeBackend) UpdateBroadcast(parts []string, b *youtube.LiveBroadcast) (*youtube.LiveBroadcast, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_UPDATE_BROADCAST); err != nil {
+		return nil, err
+	}
+
+	existing, ok := f.broadcasts[b.Id]
+	if !ok {
+		return nil, fmt.Errorf("liveBroadcastNotFound: broadcast %s does not exist", b.Id)
+	}
+	for _, p := range parts {
+		switch p {
+		case "snippet":
+			existing.Snippet = copySnippet(b.Snippet)
+		case "status":
+			s := *b.Status
+			existing.Status = &s
+		case "contentDetails", "content_details":
+			cd := *b.ContentDetails
+			existing.ContentDetails = &cd
+		}
+	}
+
+	return copyBroadcast(existing), nil
+}
+
+func (f *FakeBackend) BindBroadcast(broadcastId, liveStreamId string) (*youtube.LiveBroadcast, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_BIND_BROADCAST); err != nil {
+		return nil, err
+	}
+
+	b, ok := f.broadcasts[broadcastId]
+	if !ok {
+		return nil, fmt.Errorf("liveBroadcastNotFound: broadcast %s does not exist", broadcastId)
+	}
+	if _, ok := f.streams[liveStreamId]; !ok {
+		return nil, fmt.Errorf("liveStreamNotFound: live stream %s does not exist", liveStreamId)
+	}
+	b.ContentDetails.BoundStreamId = liveStreamId
+
+	return copyBroadcast(b), nil
+}
+
+func (f *FakeBackend) DeleteBroadcast(broadcastId string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_DELETE_BROADCAST); err != nil {
+		return err
+	}
+
+	if _, ok := f.broadcasts[broadcastId]; !ok {
+		return fmt.Errorf("liveBroadcastNotFound: broadcast %s does not exist", broadcastId)
+	}
+	delete(f.broadcasts, broadcastId)
+	delete(f.thumbnails, broadcastId)
+	return nil
+}
+
+func (f *FakeBackend) TransitionBroadcast(broadcastId, status string) (*youtube.LiveBroadcast, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_TRANSITION); err != nil {
+		return nil, err
+	}
+
+	b, ok := f.broadcasts[broadcastId]
+	if !ok {
+		return nil, fmt.Errorf("liveBroadcastNotFound: broadcast %s does not exist", broadcastId)
+	}
+	if b.ContentDetails.BoundStreamId == "" && status != TRANSITION_COMPLETE {
+		return nil, fmt.Errorf("invalidTransition: broadcast %s is not bound to a live stream", broadcastId)
+	}
+	if b.Status.LifeCycleStatus == "complete" {
+		return nil, fmt.Errorf("redundantTransition: broadcast %s is already complete", broadcastId)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	switch status {
+	case TRANSITION_LIVE:
+		b.Snippet.ActualStartTime = now
+	case TRANSITION_COMPLETE:
+		b.Snippet.ActualEndTime = now
+	}
+	b.Status.LifeCycleStatus = status
+
+	return copyBroadcast(b), nil
+}
+
+func (f *FakeBackend) ListLiveStreams() ([]*youtube.LiveStream, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_LIST_LIVESTREAMS); err != nil {
+		return nil, err
+	}
+
+	res := make([]*youtube.LiveStream, 0, len(f.streams))
+	for _, ls := range f.streams {
+		c := *ls
+		res = append(res, &c)
+	}
+	return res, nil
+}
+
+func (f *FakeBackend) InsertLiveStream(parts []string, ls *youtube.LiveStream) (*youtube.LiveStream, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_INSERT_LIVESTREAM); err != nil {
+		return nil, err
+	}
+
+	return f.addLiveStream(ls), nil
+}
+
+func (f *FakeBackend) SetThumbnail(videoId string, media io.Reader) (*youtube.ThumbnailSetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failure(FAKE_OP_SET_THUMBNAIL); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.broadcasts[videoId]; !ok {
+		return nil, fmt.Errorf("videoNotFound: video %s does not exist", videoId)
+	}
+	data, err := io.ReadAll(media)
+	if err != nil {
+		return nil, err
+	}
+	f.thumbnails[videoId] = append(f.thumbnails[videoId], data)
+
+	url := fmt.Sprintf(FAKE_THUMBNAIL_URL_PATTERN, videoId, len(f.thumbnails[videoId]))
+	return &youtube.ThumbnailSetResponse{
+		Kind: "youtube#thumbnailSetResponse",
+		Items: []*youtube.ThumbnailDetails{
+			{Default: &youtube.Thumbnail{Url: url}},
+		},
+	}, nil
+}
+
+func (f *FakeBackend) addLiveStream(ls *youtube.LiveStream) *youtube.LiveStream {
+	nls := *ls
+	if nls.Id == "" {
+		nls.Id = f.newId("stream")
+	}
+	nls.Kind = "youtube#liveStream"
+	if nls.Cdn == nil {
+		nls.Cdn = &youtube.CdnSettings{}
+	}
+	if nls.Cdn.IngestionInfo == nil {
+		nls.Cdn.IngestionInfo = &youtube.IngestionInfo{
+			StreamName:       fmt.Sprintf("key-%s", nls.Id),
+			IngestionAddress: "rtmp://a.rtmp.youtube.com/live2",
+		}
+	}
+	f.streams[nls.Id] = &nls
+
+	c := nls
+	return &c
+}
+
+func (f *FakeBackend) newId(prefix string) string {
+	f.nextId++
+	return fmt.Sprintf("fake-%s-%d", prefix, f.nextId)
+}
+
+func (f *FakeBackend) failure(op string) error {
+	err := f.failures[op]
+	delete(f.failures, op)
+	return err
+}
+
+func copyBroadcast(b *youtube.LiveBroadcast) *youtube.LiveBroadcast {
+	if b == nil {
+		return nil
+	}
+
+	c := *b
+	c.Snippet = copySnippet(b.Snippet)
+	if b.Status != nil {
+		s := *b.Status
+		c.Status = &s
+	}
+	if b.ContentDetails != nil {
+		cd := *b.ContentDetails
+		c.ContentDetails = &cd
+	}
+	return &c
+}
+
+func copySnippet(s *youtube.LiveBroadcastSnippet) *youtube.LiveBroadcastSnippet {
+	if s == nil {
+		return nil
+	}
+
+	c := *s
+	if s.Thumbnails != nil {
+		t := *s.Thumbnails
+		c.Thumbnails = &t
+	}
+	return &c
+}
@@ -0,0 +1,22 @@
+package stream
+
+import (
+	"errors"
+	"strings"
+)
+
+// joinErrors combines several errors into one, returning nil when there are none
+func joinErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
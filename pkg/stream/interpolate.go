@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ENV_REF_PREFIX  = "env:"
+	FILE_REF_PREFIX = "file:"
+)
+
+// matches an escaped "$${", a "${NAME}" reference with an optional ":-default", or any other "${" which is not a valid
+// reference
+var envVarPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}|\$\{`)
+
+// interpolate resolves the references to environment variables and files in every scalar value of the document, so
+// that secrets (such as publisher tokens) do not have to be written in the configuration itself.
+//
+// A value which is entirely "env:NAME" or "file:/path" is replaced by the environment variable or the contents of the
+// file (without its trailing newline). "${NAME}" and "${NAME:-default}" are expanded anywhere in a value, "$${" is a
+// literal "${" and any other "${" is an error
+func (v *validator) interpolate(n *yaml.Node, path string, seen map[*yaml.Node]bool) {
+	if n == nil || seen[n] {
+		return
+	}
+	seen[n] = true
+
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			v.interpolate(c, path, seen)
+		}
+	case yaml.AliasNode:
+		v.interpolate(n.Alias, path, seen)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v.interpolate(n.Content[i+1], joinPath(path, n.Content[i].Value), seen)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			v.interpolate(c, fmt.Sprintf("%s[%d]", path, i), seen)
+		}
+	case yaml.ScalarNode:
+		if n.ShortTag() != "!!str" {
+			return
+		}
+		value, err := resolveReferences(n.Value)
+		if err != nil {
+			v.errs = append(v.errs, &ValidationError{Line: n.Line, Column: n.Column, Path: path, Message: err.Error()})
+			return
+		}
+		if value == n.Value {
+			return
+		}
+		n.Value = value
+		// plain values are resolved again so that references can be used for numbers and booleans too
+		if n.Style == 0 {
+			n.Tag = ""
+		}
+	}
+}
+
+func resolveReferences(value string) (string, error) {
+	if name, ok := cutPrefix(value, ENV_REF_PREFIX); ok {
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return env, nil
+	}
+	if path, ok := cutPrefix(value, FILE_REF_PREFIX); ok {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("unable to read referenced file. %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	var errs []error
+	expanded := envVarPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		if ref == "${" {
+			errs = append(errs, fmt.Errorf(`invalid or unterminated reference in %q. use ${NAME}, ${NAME:-default} or $${ for a literal "${"`, value))
+			return ref
+		}
+		m := envVarPattern.FindStringSubmatch(ref)
+		if env, ok := os.LookupEnv(m[1]); ok {
+			return env
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		errs = append(errs, fmt.Errorf("environment variable %s is not set", m[1]))
+		return ref
+	})
+	return expanded, joinErrors(errs)
+}
+
+// cutPrefix returns the rest of a value which is entirely a reference, such as "env:NAME". Values with whitespace are
+// not references, so that prose such as "file: see below" is left alone
+func cutPrefix(value, prefix string) (string, bool) {
+	if !strings.HasPrefix(value, prefix) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(value, prefix)
+	if rest == "" || strings.ContainsAny(rest, " \t\n") {
+		return "", false
+	}
+	return rest, true
+}
@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sykesdev.ca/yls/pkg/pub"
+)
+
+func TestResolveReferences(t *testing.T) {
+	t.Setenv("YLS_TEST_TOKEN", "secret")
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-secret\r\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "set variable", value: "Bearer ${YLS_TEST_TOKEN}", want: "Bearer secret"},
+		{name: "set variable with default", value: "${YLS_TEST_TOKEN:-fallback}", want: "secret"},
+		{name: "unset variable with default", value: "${YLS_TEST_UNSET:-fallback}", want: "fallback"},
+		{name: "unset variable with empty default", value: "a${YLS_TEST_UNSET:-}b", want: "ab"},
+		{name: "unset variable", value: "${YLS_TEST_UNSET}", wantErr: "environment variable YLS_TEST_UNSET is not set"},
+		{name: "unterminated reference", value: "${YLS_TEST_TOKEN", wantErr: `invalid or unterminated reference in "${YLS_TEST_TOKEN"`},
+		{name: "invalid variable name", value: "${1TOKEN}", wantErr: `invalid or unterminated reference in "${1TOKEN}"`},
+		{name: "escaped reference", value: "$${YLS_TEST_TOKEN} costs $$5", want: "${YLS_TEST_TOKEN} costs $$5"},
+		{name: "env reference", value: "env:YLS_TEST_TOKEN", want: "secret"},
+		{name: "unset env reference", value: "env:YLS_TEST_UNSET", wantErr: "environment variable YLS_TEST_UNSET is not set"},
+		{name: "file reference trims trailing newlines", value: "file:" + tokenFile, want: "file-secret"},
+		{name: "missing file reference", value: "file:" + filepath.Join(dir, "missing"), wantErr: "unable to read referenced file"},
+		{name: "prose is not a reference", value: "file: see below", want: "file: see below"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveReferences(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected %q to resolve, got %s", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInterpolatePublisherConfig(t *testing.T) {
+	t.Setenv("YLS_TEST_WP_USER", "editor")
+	tokenFile := filepath.Join(t.TempDir(), "wordpress_app_token")
+	if err := os.WriteFile(tokenFile, []byte("app-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * * 0"
+    publisher:
+      wordpress:
+        host: example.com
+        username: ${YLS_TEST_WP_USER}
+        appToken: file:` + tokenFile + `
+        data:
+          meta:
+            slug: ${YLS_TEST_WP_SLUG:-sunday-service}
+          content: "$${not a reference}"
+`
+	streams, err := Validate([]byte(config))
+	if err != nil {
+		t.Fatalf("expected the configuration to be valid, got %s", err)
+	}
+	cfg, ok := streams.Items[0].Publishers[0].Config.(*pub.WordpressConfig)
+	if !ok {
+		t.Fatalf("expected a wordpress configuration, got %T", streams.Items[0].Publishers[0].Config)
+	}
+	if cfg.Username != "editor" {
+		t.Errorf("expected username from the environment, got %q", cfg.Username)
+	}
+	if cfg.AppToken != "app-token" {
+		t.Errorf("expected app token from the file, got %q", cfg.AppToken)
+	}
+	if cfg.Data.Meta.Slug != "sunday-service" {
+		t.Errorf("expected the default slug, got %q", cfg.Data.Meta.Slug)
+	}
+	if cfg.Data.Content != "${not a reference}" {
+		t.Errorf("expected the escaped content, got %q", cfg.Data.Content)
+	}
+
+	_, err = Validate([]byte(strings.Replace(config, "${YLS_TEST_WP_USER}", "${YLS_TEST_UNSET}", 1)))
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 {
+		t.Fatalf("expected 1 validation error, got %v", err)
+	}
+	if got := verrs[0].Error(); got != "10:19: streams[0].publisher.wordpress.username: environment variable YLS_TEST_UNSET is not set" {
+		t.Errorf("unexpected validation error %q", got)
+	}
+}
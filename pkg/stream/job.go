@@ -0,0 +1,173 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"sykesdev.ca/yls/pkg/logging"
+	"sykesdev.ca/yls/pkg/pub"
+	"sykesdev.ca/yls/pkg/state"
+)
+
+const (
+	JOB_STEP_PREPARE    = "prepare"
+	JOB_STEP_BROADCAST  = "broadcast"
+	JOB_STEP_LIVESTREAM = "liveStream"
+	JOB_STEP_THUMBNAILS = "thumbnails"
+	JOB_STEP_UPDATE     = "update"
+	JOB_STEP_PUBLISH    = "publish"
+	JOB_STEP_NOTIFY     = "notify"
+)
+
+// Job returns the scheduled job of the stream. Unlike Upload, a job which failed because the API quota is exhausted is
+// re-attempted later when the retry policy allows it
+func (u *StreamUploadClient) Job(s *Stream) func() {
+	return func() {
+		u.runJob(s, time.Now())
+	}
+}
+
+// runJob runs the job of the stream for the occurrence at t. A re-attempt runs for that same occurrence, so that it
+// creates the broadcast the failed job was meant to create
+func (u *StreamUploadClient) runJob(s *Stream, t time.Time) {
+	if _, err := u.run(s, t); err != nil {
+		u.reattemptOnQuota(s.Name, err, func() { u.runJob(s, t) })
+	}
+}
+
+// run runs the job of the stream for the occurrence at t, then logs and persists its result. The error which stopped
+// the job, if any, is returned alongside the result
+func (u *StreamUploadClient) run(s *Stream, t time.Time) (*state.JobResult, error) {
+	res, err := u.upload(s, t)
+	if res.Failed() {
+		u.notifyFailure(s, res)
+	}
+	// the job finishes once the failure notifications, which are steps of the job as well, have been sent
+	res.FinishedAt = time.Now()
+
+	fields := []zap.Field{
+		zap.String("streamName", res.StreamName),
+		zap.String("occurrence", res.OccurrenceKey),
+		zap.String("broadcastId", res.BroadcastID),
+		zap.Duration("duration", res.FinishedAt.Sub(res.StartedAt)),
+	}
+	if res.Failed() {
+		logging.YLSLogger().Error("job completed with failures", append(fields, zap.Strings("failedSteps", res.FailedSteps()))...)
+	} else {
+		logging.YLSLogger().Info("job completed successfully", fields...)
+	}
+
+	if err := u.state.PutJob(res); err != nil {
+		logging.YLSLogger().Error("failed to record job result in state store", zap.String("streamName", res.StreamName), zap.Error(err))
+	}
+	return res, err
+}
+
+// notifyFailure reports a failed job using the publishers of the stream which fire on failure, recording the outcome of
+// each notification with the job
+func (u *StreamUploadClient) notifyFailure(s *Stream, res *state.JobResult) {
+	notifiers := s.Publishers.On(pub.PUBLISH_ON_FAILURE)
+	if len(notifiers) == 0 {
+		return
+	}
+	if u.dryRun {
+		logging.YLSLogger().Info("would have notified publishers of the failed job, but is dry-run", zap.String("streamName", s.Name), zap.Stringer("publishers", s.Publishers))
+		return
+	}
+
+	failure := &pub.JobFailure{
+		StreamName:    res.StreamName,
+		OccurrenceKey: res.OccurrenceKey,
+		BroadcastID:   res.BroadcastID,
+	}
+	for _, step := range res.Steps {
+		if step.Status == state.STEP_STATUS_FAILED {
+			failure.Steps = append(failure.Steps, pub.FailedStep{Step: step.Step, Error: step.Error})
+		}
+	}
+
+	for _, cfg := range notifiers {
+		p, err := cfg.GetPublisher()
+		if err == nil {
+			if n, ok := p.(pub.FailureNotifier); ok {
+				err = n.NotifyFailure(failure, s)
+			} else {
+				err = fmt.Errorf("publishers of type %s cannot notify about failed jobs", cfg.Type)
+			}
+		}
+		if err != nil {
+			logging.YLSLogger().Error("unable to notify publisher of the failed job", zap.String("streamName", s.Name), zap.Stringer("publisher", cfg), zap.Error(err))
+		}
+		res.Step(JOB_STEP_NOTIFY+":"+cfg.String(), err)
+	}
+}
+
+// reattemptOnQuota schedules the job to run again later when it failed because the API quota is exhausted, if the
+// retry policy allows it
+func (u *StreamUploadClient) reattemptOnQuota(streamName string, err error, job func()) {
+	var qe *QuotaError
+	if !errors.As(err, &qe) {
+		return
+	}
+	if u.retry.QuotaRetryAfter <= 0 {
+		logging.YLSLogger().Error("youtube api quota exhausted. the job will not be re-attempted until its next scheduled run", zap.String("streamName", streamName), zap.Error(err))
+		return
+	}
+
+	delay := u.retry.QuotaRetryAfter
+	if qe.RetryAfter > delay {
+		delay = qe.RetryAfter
+	}
+	logging.YLSLogger().Error("youtube api quota exhausted. the job will be re-attempted later",
+		zap.String("streamName", streamName),
+		zap.Time("reattemptAt", time.Now().Add(delay)),
+		zap.Error(err),
+	)
+
+	u.runAfter(u.reattempts, streamName, delay, job)
+}
+
+// runAfter runs job once after delay. pending holds the jobs waiting to run by stream name, and a job already pending
+// for the stream is stopped and replaced
+func (u *StreamUploadClient) runAfter(pending map[string]*reattempt, streamName string, delay time.Duration, job func()) {
+	afterFunc := u.afterFunc
+	if afterFunc == nil {
+		afterFunc = func(d time.Duration, f func()) func() bool { return time.AfterFunc(d, f).Stop }
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if p, ok := pending[streamName]; ok {
+		p.stop()
+	}
+	r := &reattempt{}
+	r.stop = afterFunc(delay, func() {
+		u.mu.Lock()
+		if pending[streamName] == r {
+			delete(pending, streamName)
+		}
+		u.mu.Unlock()
+		job()
+	})
+	pending[streamName] = r
+}
+
+// CancelReattempt stops the pending re-attempt of the job and the pending max duration re-check of the named stream, if
+// there are any. It is used when the stream is removed from the scheduler or replaced by a new configuration
+func (u *StreamUploadClient) CancelReattempt(streamName string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if r, ok := u.reattempts[streamName]; ok {
+		r.stop()
+		delete(u.reattempts, streamName)
+		logging.YLSLogger().Info("cancelled pending re-attempt of job", zap.String("streamName", streamName))
+	}
+	if r, ok := u.maxDurationChecks[streamName]; ok {
+		r.stop()
+		delete(u.maxDurationChecks, streamName)
+		logging.YLSLogger().Info("cancelled pending max duration re-check", zap.String("streamName", streamName))
+	}
+}
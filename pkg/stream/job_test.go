@@ -0,0 +1,263 @@
+package stream
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+
+	"sykesdev.ca/yls/pkg/pub"
+	"sykesdev.ca/yls/pkg/state"
+)
+
+// recordingNotifier is a publisher which records when it was notified about failed jobs
+type recordingNotifier struct {
+	failures   []*pub.JobFailure
+	notifiedAt time.Time
+}
+
+func (n *recordingNotifier) Publisher() (pub.Publisher, error) { return n, nil }
+func (n *recordingNotifier) Validate() []pub.ConfigError       { return nil }
+
+func (n *recordingNotifier) Publish(*youtube.LiveBroadcast, interface{}) error { return nil }
+func (n *recordingNotifier) Unpublish(*youtube.LiveBroadcast) error            { return nil }
+
+func (n *recordingNotifier) NotifyFailure(failure *pub.JobFailure, publishVars interface{}) error {
+	n.failures = append(n.failures, failure)
+	n.notifiedAt = time.Now()
+	return nil
+}
+
+// fakeTimers replaces time.AfterFunc in the uploader so that tests fire re-attempts themselves instead of waiting
+type fakeTimers struct {
+	mu     sync.Mutex
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	delay   time.Duration
+	f       func()
+	stopped bool
+}
+
+// useFakeTimers makes the uploader schedule its re-attempts with fake timers
+func useFakeTimers(u *StreamUploadClient) *fakeTimers {
+	ft := &fakeTimers{}
+	u.afterFunc = ft.afterFunc
+	return ft
+}
+
+func (ft *fakeTimers) afterFunc(d time.Duration, f func()) func() bool {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	t := &fakeTimer{delay: d, f: f}
+	ft.timers = append(ft.timers, t)
+	return func() bool {
+		ft.mu.Lock()
+		defer ft.mu.Unlock()
+		stopped := !t.stopped
+		t.stopped = true
+		return stopped
+	}
+}
+
+// pending returns the timers which have not been stopped or fired yet
+func (ft *fakeTimers) pending() []*fakeTimer {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	var res []*fakeTimer
+	for _, t := range ft.timers {
+		if !t.stopped {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// fire runs the function of the timer as if it had expired
+func (ft *fakeTimers) fire(t *fakeTimer) {
+	ft.mu.Lock()
+	t.stopped = true
+	ft.mu.Unlock()
+	t.f()
+}
+
+func quotaError() error {
+	return &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}}
+}
+
+func TestJobReattemptsOnQuota(t *testing.T) {
+	u, fake, _ := newTestUploader(t, withQuotaRetryAfter(time.Hour))
+	timers := useFakeTimers(u)
+	fake.FailNext(FAKE_OP_INSERT_BROADCAST, quotaError())
+
+	u.Job(newTestStream())()
+	if n := len(fake.Broadcasts()); n != 0 {
+		t.Fatalf("expected the job to fail, got %d broadcasts", n)
+	}
+	pending := timers.pending()
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 pending re-attempt, got %d", len(pending))
+	}
+	if pending[0].delay != time.Hour {
+		t.Errorf("expected the job to be re-attempted after %s, got %s", time.Hour, pending[0].delay)
+	}
+
+	timers.fire(pending[0])
+	if n := len(fake.Broadcasts()); n != 1 {
+		t.Errorf("expected the re-attempt to create 1 broadcast, got %d", n)
+	}
+	if len(u.reattempts) != 0 {
+		t.Errorf("expected no pending re-attempts after the re-attempt ran, got %d", len(u.reattempts))
+	}
+}
+
+func TestJobReattemptTargetsOriginalOccurrence(t *testing.T) {
+	u, fake, _ := newTestUploader(t, withQuotaRetryAfter(time.Hour))
+	timers := useFakeTimers(u)
+	s := newTestStream()
+	s.Schedule = "0 * * * *"
+	s.Timezone = "UTC"
+	fake.FailNext(FAKE_OP_INSERT_BROADCAST, quotaError())
+
+	// the job ran two hours ago, so the re-attempt runs in a later slot of the schedule
+	ranAt := time.Now().Add(-2 * time.Hour)
+	u.runJob(s, ranAt)
+	pending := timers.pending()
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 pending re-attempt, got %d", len(pending))
+	}
+	timers.fire(pending[0])
+
+	broadcasts := fake.Broadcasts()
+	if len(broadcasts) != 1 {
+		t.Fatalf("expected the re-attempt to create 1 broadcast, got %d", len(broadcasts))
+	}
+	want := s.OccurrenceKey(s.OccurrenceSlot(ranAt))
+	if key, _ := OccurrenceKeyOf(broadcasts[0]); key != want {
+		t.Errorf("expected the re-attempt to create the broadcast of occurrence %s, got %s", want, key)
+	}
+	start, err := time.Parse(time.RFC3339, broadcasts[0].Snippet.ScheduledStartTime)
+	if err != nil || start.Before(ranAt.Add(time.Hour)) {
+		t.Errorf("expected the broadcast to start after the re-attempt, got %q", broadcasts[0].Snippet.ScheduledStartTime)
+	}
+}
+
+func TestJobReattemptReplacesPendingReattempt(t *testing.T) {
+	u, fake, _ := newTestUploader(t, withQuotaRetryAfter(time.Hour))
+	timers := useFakeTimers(u)
+	job := u.Job(newTestStream())
+
+	fake.FailNext(FAKE_OP_INSERT_BROADCAST, quotaError())
+	job()
+	fake.FailNext(FAKE_OP_INSERT_BROADCAST, quotaError())
+	job()
+
+	if n := len(timers.pending()); n != 1 {
+		t.Errorf("expected the earlier re-attempt to be stopped, got %d pending re-attempts", n)
+	}
+}
+
+func TestJobQuotaWithoutReattempts(t *testing.T) {
+	u, fake, _ := newTestUploader(t)
+	timers := useFakeTimers(u)
+	fake.FailNext(FAKE_OP_INSERT_BROADCAST, quotaError())
+
+	u.Job(newTestStream())()
+	if n := len(timers.pending()); n != 0 {
+		t.Errorf("expected no re-attempts when they are disabled, got %d", n)
+	}
+}
+
+func TestCancelReattempt(t *testing.T) {
+	u, fake, _ := newTestUploader(t, withQuotaRetryAfter(time.Hour))
+	timers := useFakeTimers(u)
+	fake.FailNext(FAKE_OP_INSERT_BROADCAST, quotaError())
+	s := newTestStream()
+
+	u.Job(s)()
+	u.CancelReattempt(s.Name)
+
+	if n := len(timers.pending()); n != 0 {
+		t.Errorf("expected the re-attempt to be stopped, got %d pending re-attempts", n)
+	}
+	if len(u.reattempts) != 0 {
+		t.Errorf("expected no pending re-attempts, got %d", len(u.reattempts))
+	}
+}
+
+func TestJobFinishesAfterFailureNotifications(t *testing.T) {
+	u, fake, st := newTestUploader(t)
+	notifier := &recordingNotifier{}
+	s := newTestStream()
+	s.Publishers = pub.Publishers{{Type: "recording", On: []string{pub.PUBLISH_ON_FAILURE}, Config: notifier}}
+	fake.FailNext(FAKE_OP_INSERT_BROADCAST, errors.New("backend unavailable"))
+
+	res := u.Upload(s)
+	if len(notifier.failures) != 1 {
+		t.Fatalf("expected 1 failure notification, got %d", len(notifier.failures))
+	}
+	if got := stepStatus(res, JOB_STEP_NOTIFY+":recording"); got != state.STEP_STATUS_SUCCEEDED {
+		t.Errorf("expected the notification to be recorded as a step, got %q", got)
+	}
+	if res.FinishedAt.Before(notifier.notifiedAt) {
+		t.Errorf("expected the job to finish after the notifications, finished at %s and notified at %s", res.FinishedAt, notifier.notifiedAt)
+	}
+
+	recorded, ok := st.Job(s.Name)
+	if !ok {
+		t.Fatal("expected the job result to be recorded")
+	}
+	if !recorded.FinishedAt.Equal(res.FinishedAt) || stepStatus(recorded, JOB_STEP_NOTIFY+":recording") == "" {
+		t.Errorf("expected the recorded job result to include the notifications, got %+v", recorded)
+	}
+}
+
+func TestJobNotifiesFailurePublishers(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], string(body))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	u, fake, _ := newTestUploader(t)
+	s := newTestStream()
+	s.Publishers = pub.Publishers{
+		{Type: pub.PUBLISHER_SLACK, Name: "alerts", On: []string{pub.PUBLISH_ON_FAILURE}, Config: &pub.SlackConfig{WebhookURL: srv.URL + "/alerts"}},
+		{Type: pub.PUBLISHER_SLACK, Name: "announcements", Config: &pub.SlackConfig{WebhookURL: srv.URL + "/announcements"}},
+	}
+	fake.FailNext(FAKE_OP_INSERT_BROADCAST, errors.New("backend unavailable"))
+
+	res := u.Upload(s)
+	if len(received["/alerts"]) != 1 {
+		t.Fatalf("expected the failure publisher to be notified once, got %d notifications", len(received["/alerts"]))
+	}
+	if !strings.Contains(received["/alerts"][0], s.Name) {
+		t.Errorf("expected the notification to name the stream %s, got %s", s.Name, received["/alerts"][0])
+	}
+	if len(received["/announcements"]) != 0 {
+		t.Errorf("expected the success publisher not to be notified of the failed job, got %v", received["/announcements"])
+	}
+	if got := stepStatus(res, JOB_STEP_NOTIFY+":alerts"); got != state.STEP_STATUS_SUCCEEDED {
+		t.Errorf("expected the notification to succeed, got %q", got)
+	}
+
+	received = map[string][]string{}
+	u.Upload(s)
+	if len(received["/alerts"]) != 0 {
+		t.Errorf("expected the failure publisher not to be notified of a successful job, got %v", received["/alerts"])
+	}
+}
@@ -0,0 +1,203 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/youtube/v3"
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+const (
+	TRANSITION_TESTING  = "testing"
+	TRANSITION_LIVE     = "live"
+	TRANSITION_COMPLETE = "complete"
+)
+
+var TRANSITIONS_ALLOWED = []string{TRANSITION_TESTING, TRANSITION_LIVE, TRANSITION_COMPLETE}
+
+// Transition changes the lifecycle status of a broadcast to one of TRANSITIONS_ALLOWED
+func (u *StreamUploadClient) Transition(broadcastId, status string) (*youtube.LiveBroadcast, error) {
+	if !helper.StringInSlice(status, TRANSITIONS_ALLOWED) {
+		return nil, fmt.Errorf("invalid broadcast transition %q. must be one of [%s]", status, strings.Join(TRANSITIONS_ALLOWED, ", "))
+	}
+
+	if u.dryRun {
+		logging.YLSLogger().Info("would have transitioned LiveBroadcast resource, but is dry-run",
+			zap.String("broadcastId", broadcastId),
+			zap.String("status", status),
+		)
+		return nil, nil
+	}
+
+	b, err := u.backend.TransitionBroadcast(broadcastId, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to transition broadcast %s to %s. %w", broadcastId, status, err)
+	}
+
+	logging.YLSLogger().Info("transitioned live broadcast",
+		zap.String("broadcastId", broadcastId),
+		zap.String("status", status),
+	)
+	return b, nil
+}
+
+// ResolveBroadcast finds the broadcast of a stream that a transition applies to. Completing applies to the active
+// broadcast of the stream, while testing and going live apply to its next upcoming broadcast
+func (u *StreamUploadClient) ResolveBroadcast(streamName, status string) (*youtube.LiveBroadcast, error) {
+	filter := BROADCAST_STATUS_UPCOMING
+	if status == TRANSITION_COMPLETE {
+		filter = BROADCAST_STATUS_ACTIVE
+	}
+
+	broadcasts, err := u.broadcastsOf(streamName, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var next *youtube.LiveBroadcast
+	var nextStart time.Time
+	for _, b := range broadcasts {
+		if b.Snippet == nil {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, b.Snippet.ScheduledStartTime)
+		if err != nil {
+			logging.YLSLogger().Warn("unable to parse the scheduled start of broadcast. skipping it",
+				zap.String("broadcastId", b.Id),
+				zap.String("scheduledStart", b.Snippet.ScheduledStartTime),
+				zap.Error(err),
+			)
+			continue
+		}
+		if next == nil || start.Before(nextStart) {
+			next, nextStart = b, start
+		}
+	}
+	return next, nil
+}
+
+// KnownStream reports whether broadcasts have been recorded for the named stream
+func (u *StreamUploadClient) KnownStream(streamName string) bool {
+	return streamName != "" && len(u.state.List(streamName)) > 0
+}
+
+// broadcastsOf lists the broadcasts with the given status that were created for the named stream
+func (u *StreamUploadClient) broadcastsOf(streamName, status string) ([]*youtube.LiveBroadcast, error) {
+	broadcasts, err := u.backend.ListBroadcasts(status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s broadcasts. %w", status, err)
+	}
+
+	res := []*youtube.LiveBroadcast{}
+	for _, b := range broadcasts {
+		key, _ := OccurrenceKeyOf(b)
+		if u.streamNameOf(b.Id, key) == streamName {
+			res = append(res, b)
+		}
+	}
+	return res, nil
+}
+
+// Complete returns a job which completes every active broadcast of the stream. It is scheduled on the stream's
+// EndSchedule so that broadcasts the encoder forgot to stop do not stay live
+func (u *StreamUploadClient) Complete(s *Stream) func() {
+	return func() {
+		u.completeActive(s, func(b *youtube.LiveBroadcast) bool { return true })
+	}
+}
+
+// EnforceMaxDuration returns a job which completes every active broadcast of the stream that has been live for longer
+// than the stream's MaxDurationMinutes. Since the maximum duration is measured from when a broadcast actually went live,
+// the job checks again once the active broadcasts reach it, and every MAX_DURATION_RECHECK_INTERVAL while a broadcast
+// of the stream is late to go live
+func (u *StreamUploadClient) EnforceMaxDuration(s *Stream) func() {
+	maxDuration := time.Duration(s.MaxDurationMinutes) * time.Minute
+	var job func()
+	job = func() {
+		var recheck time.Duration
+		err := u.completeActive(s, func(b *youtube.LiveBroadcast) bool {
+			started, err := time.Parse(time.RFC3339, b.Snippet.ActualStartTime)
+			if err != nil {
+				recheck = MAX_DURATION_RECHECK_INTERVAL
+				return false
+			}
+			remaining := maxDuration - time.Since(started)
+			if remaining <= 0 {
+				return true
+			}
+			if recheck == 0 || remaining < recheck {
+				recheck = remaining
+			}
+			return false
+		})
+		if err != nil || (recheck == 0 && u.hasLateBroadcast(s.Name)) {
+			recheck = MAX_DURATION_RECHECK_INTERVAL
+		}
+		if recheck == 0 {
+			return
+		}
+
+		logging.YLSLogger().Info("checking the max duration of the stream again later",
+			zap.String("streamName", s.Name),
+			zap.Time("recheckAt", time.Now().Add(recheck)),
+		)
+		u.runAfter(u.maxDurationChecks, s.Name, recheck, job)
+	}
+	return job
+}
+
+// hasLateBroadcast reports whether the named stream has an upcoming broadcast which should have started already, but
+// not longer than MAX_DURATION_LATE_START ago
+func (u *StreamUploadClient) hasLateBroadcast(streamName string) bool {
+	upcoming, err := u.broadcastsOf(streamName, BROADCAST_STATUS_UPCOMING)
+	if err != nil {
+		logging.YLSLogger().Error("unable to get upcoming broadcasts for stream", zap.String("streamName", streamName), zap.Error(err))
+		return true
+	}
+
+	now := time.Now()
+	for _, b := range upcoming {
+		if b.Snippet == nil {
+			continue
+		}
+		start, err := time.Parse(time.RFC3339, b.Snippet.ScheduledStartTime)
+		if err == nil && start.Before(now) && now.Sub(start) < MAX_DURATION_LATE_START {
+			return true
+		}
+	}
+	return false
+}
+
+// completeActive completes the active broadcasts of the stream for which shouldComplete returns true. Broadcasts
+// without a snippet are skipped
+func (u *StreamUploadClient) completeActive(s *Stream, shouldComplete func(b *youtube.LiveBroadcast) bool) error {
+	active, err := u.broadcastsOf(s.Name, BROADCAST_STATUS_ACTIVE)
+	if err != nil {
+		logging.YLSLogger().Error("unable to get active broadcasts for stream", zap.String("streamName", s.Name), zap.Error(err))
+		return err
+	}
+
+	for _, b := range active {
+		if b.Snippet == nil || !shouldComplete(b) {
+			continue
+		}
+
+		logging.YLSLogger().Info("completing active broadcast for stream",
+			zap.String("streamName", s.Name),
+			zap.String("broadcastId", b.Id),
+			zap.String("actualStart", b.Snippet.ActualStartTime),
+		)
+		if _, err := u.Transition(b.Id, TRANSITION_COMPLETE); err != nil {
+			logging.YLSLogger().Error("unable to complete active broadcast for stream",
+				zap.String("streamName", s.Name),
+				zap.String("broadcastId", b.Id),
+				zap.Error(err),
+			)
+		}
+	}
+	return nil
+}
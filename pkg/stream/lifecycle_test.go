@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"sykesdev.ca/yls/pkg/state"
+)
+
+func TestResolveBroadcastComparesScheduledStarts(t *testing.T) {
+	u, fake, _ := newTestUploader(t)
+
+	// 9:00 in Toronto is later than 10:00 UTC, although it sorts first as a string
+	for _, start := range []string{"2023-03-05T09:00:00-05:00", "2023-03-05T10:00:00Z", "not a time"} {
+		_, err := fake.InsertBroadcast([]string{"snippet", "status"}, &youtube.LiveBroadcast{
+			Snippet: &youtube.LiveBroadcastSnippet{
+				Title:              "Sunday Service",
+				Description:        withOccurrenceTag("", "sunday-service@"+start),
+				ScheduledStartTime: start,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := u.ResolveBroadcast("sunday-service", TRANSITION_LIVE)
+	if err != nil {
+		t.Fatalf("failed to resolve broadcast. %s", err)
+	}
+	if b == nil || b.Snippet.ScheduledStartTime != "2023-03-05T10:00:00Z" {
+		t.Errorf("expected the earliest broadcast to be resolved, got %+v", b)
+	}
+
+	if b, err := u.ResolveBroadcast("other-stream", TRANSITION_LIVE); err != nil || b != nil {
+		t.Errorf("expected no broadcast for another stream, got %+v, %v", b, err)
+	}
+}
+
+// insertTestBroadcast adds a broadcast of the sunday-service stream to the fake with the given lifecycle status. start
+// is the scheduled start and actualStart is when it went live, if it did
+func insertTestBroadcast(t *testing.T, fake *FakeBackend, status string, start, actualStart time.Time) *youtube.LiveBroadcast {
+	t.Helper()
+
+	b, err := fake.InsertBroadcast([]string{"snippet", "status"}, &youtube.LiveBroadcast{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Snippet = &youtube.LiveBroadcastSnippet{
+		Title:              "Sunday Service",
+		Description:        withOccurrenceTag("", "sunday-service@"+start.Format("2006-01-02T15:04")),
+		ScheduledStartTime: start.UTC().Format(time.RFC3339),
+	}
+	if !actualStart.IsZero() {
+		b.Snippet.ActualStartTime = actualStart.UTC().Format(time.RFC3339)
+	}
+	b.Status.LifeCycleStatus = status
+	if b, err = fake.UpdateBroadcast([]string{"snippet", "status"}, b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEnforceMaxDurationCompletesBroadcastsWhichWentLiveLate(t *testing.T) {
+	u, fake, _ := newTestUploader(t)
+	timers := useFakeTimers(u)
+	s := newTestStream()
+	s.MaxDurationMinutes = 60
+
+	// the encoder went live an hour and a half late, so the broadcast has only been live for 30 minutes at the deadline
+	now := time.Now()
+	b := insertTestBroadcast(t, fake, "live", now.Add(-2*time.Hour), now.Add(-30*time.Minute))
+	u.EnforceMaxDuration(s)()
+	if got := fake.Broadcast(b.Id).Status.LifeCycleStatus; got != "live" {
+		t.Fatalf("expected the broadcast to stay live, got %s", got)
+	}
+	pending := timers.pending()
+	if len(pending) != 1 {
+		t.Fatalf("expected 1 pending re-check, got %d", len(pending))
+	}
+	if pending[0].delay < 29*time.Minute || pending[0].delay > 30*time.Minute {
+		t.Errorf("expected the broadcast to be checked again once it reaches its maximum duration, got a re-check after %s", pending[0].delay)
+	}
+
+	b.Snippet.ActualStartTime = now.Add(-61 * time.Minute).UTC().Format(time.RFC3339)
+	if _, err := fake.UpdateBroadcast([]string{"snippet"}, b); err != nil {
+		t.Fatal(err)
+	}
+	timers.fire(pending[0])
+	if got := fake.Broadcast(b.Id).Status.LifeCycleStatus; got != TRANSITION_COMPLETE {
+		t.Errorf("expected the re-check to complete the broadcast, got %s", got)
+	}
+	if n := len(timers.pending()); n != 0 {
+		t.Errorf("expected no re-checks once the broadcast is complete, got %d", n)
+	}
+}
+
+func TestEnforceMaxDurationWaitsForLateBroadcasts(t *testing.T) {
+	u, fake, _ := newTestUploader(t)
+	timers := useFakeTimers(u)
+	s := newTestStream()
+	s.MaxDurationMinutes = 60
+
+	// stale broadcasts which never went live are not waited for
+	insertTestBroadcast(t, fake, "ready", time.Now().Add(-2*MAX_DURATION_LATE_START), time.Time{})
+	u.EnforceMaxDuration(s)()
+	if n := len(timers.pending()); n != 0 {
+		t.Fatalf("expected no re-checks without late broadcasts, got %d", n)
+	}
+
+	insertTestBroadcast(t, fake, "ready", time.Now().Add(-2*time.Hour), time.Time{})
+	u.EnforceMaxDuration(s)()
+	pending := timers.pending()
+	if len(pending) != 1 || pending[0].delay != MAX_DURATION_RECHECK_INTERVAL {
+		t.Fatalf("expected a re-check after %s while the broadcast is late, got %+v", MAX_DURATION_RECHECK_INTERVAL, pending)
+	}
+
+	u.CancelReattempt(s.Name)
+	if n := len(timers.pending()); n != 0 {
+		t.Errorf("expected the re-check to be stopped, got %d pending re-checks", n)
+	}
+}
+
+func TestEnforceMaxDurationSkipsBroadcastsWithoutSnippet(t *testing.T) {
+	u, fake, st := newTestUploader(t)
+	s := newTestStream()
+	s.MaxDurationMinutes = 60
+
+	b := insertTestBroadcast(t, fake, "live", time.Now().Add(-3*time.Hour), time.Now().Add(-2*time.Hour))
+	b.Snippet = nil
+	if _, err := fake.UpdateBroadcast([]string{"snippet"}, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Put(&state.BroadcastRecord{StreamName: s.Name, BroadcastID: b.Id}); err != nil {
+		t.Fatal(err)
+	}
+
+	u.EnforceMaxDuration(s)()
+	if got := fake.Broadcast(b.Id).Status.LifeCycleStatus; got != "live" {
+		t.Errorf("expected the broadcast without a snippet to be skipped, got %s", got)
+	}
+}
@@ -0,0 +1,300 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/logging"
+)
+
+const (
+	ERROR_CLASS_RETRYABLE = "retryable"
+	ERROR_CLASS_QUOTA     = "quota"
+	ERROR_CLASS_FATAL     = "fatal"
+)
+
+// reasons reported by the YouTube Data API when a request is rate limited, and should simply be tried again later
+var RATE_LIMIT_REASONS = []string{"rateLimitExceeded", "userRateLimitExceeded", "backendError"}
+
+// reasons reported by the YouTube Data API when the daily quota of the project is exhausted. Retrying will not
+// succeed until the quota is reset (at midnight Pacific Time)
+var QUOTA_REASONS = []string{"quotaExceeded", "dailyLimitExceeded", "dailyLimitExceededUnreg"}
+
+// RetryPolicy configures how failed YouTube Data API calls are retried. The backoff before each retry grows from
+// InitialBackoff by Multiplier up to MaxBackoff, with a random Jitter (as a fraction of the backoff) applied. A
+// Retry-After sent by the API is respected when it is longer
+type RetryPolicy struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	// QuotaRetryAfter re-attempts a job that failed because the API quota was exhausted after this long. Zero disables
+	// re-attempts
+	QuotaRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy is used when no RetryPolicy is configured
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:       5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// backoff returns how long to wait before the given retry (starting at 1)
+func (p *RetryPolicy) backoff(retry int, retryAfter time.Duration) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(helper.DefaultValue(p.Multiplier, 2, 0), float64(retry-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if retryAfter > time.Duration(d) {
+		return retryAfter
+	}
+	return time.Duration(d)
+}
+
+// QuotaError is returned when a call failed because the daily quota of the API project is exhausted
+type QuotaError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("youtube api quota exhausted. %s", e.Err)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return e.Err
+}
+
+// IsQuotaExceeded reports whether the error was caused by the API quota being exhausted
+func IsQuotaExceeded(err error) bool {
+	var qe *QuotaError
+	return errors.As(err, &qe)
+}
+
+// ClassifyError decides whether a failed call may be retried (retryable), will fail until the API quota is reset
+// (quota) or will always fail (fatal). The delay requested by the API through Retry-After is returned as well
+func ClassifyError(err error) (string, time.Duration) {
+	if err == nil {
+		return "", 0
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ERROR_CLASS_FATAL, 0
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		retryAfter := parseRetryAfter(apiErr.Header.Get("Retry-After"))
+		for _, item := range apiErr.Errors {
+			if helper.StringInSlice(item.Reason, QUOTA_REASONS) {
+				return ERROR_CLASS_QUOTA, retryAfter
+			}
+		}
+		for _, item := range apiErr.Errors {
+			if helper.StringInSlice(item.Reason, RATE_LIMIT_REASONS) {
+				return ERROR_CLASS_RETRYABLE, retryAfter
+			}
+		}
+		switch apiErr.Code {
+		case http.StatusTooManyRequests, http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return ERROR_CLASS_RETRYABLE, retryAfter
+		}
+		return ERROR_CLASS_FATAL, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ERROR_CLASS_RETRYABLE, 0
+	}
+	return ERROR_CLASS_FATAL, 0
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// retryingBackend retries the calls of another BroadcastBackend according to a RetryPolicy
+type retryingBackend struct {
+	ctx     context.Context
+	backend BroadcastBackend
+	policy  RetryPolicy
+}
+
+// WithRetries wraps a BroadcastBackend so that calls failing with retryable errors are retried, and calls failing
+// because the API quota is exhausted return a QuotaError
+func WithRetries(ctx context.Context, backend BroadcastBackend, policy RetryPolicy) BroadcastBackend {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &retryingBackend{
+		ctx:     ctx,
+		backend: backend,
+		policy:  policy,
+	}
+}
+
+func (r *retryingBackend) do(op string, call func() error) error {
+	attempts := helper.DefaultValue(r.policy.Attempts, 1, 0)
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+
+		class, retryAfter := ClassifyError(err)
+		switch {
+		case class == ERROR_CLASS_QUOTA:
+			return &QuotaError{Err: err, RetryAfter: retryAfter}
+		case class != ERROR_CLASS_RETRYABLE:
+			return err
+		case attempt >= attempts:
+			return fmt.Errorf("%s failed after %d attempts. %w", op, attempt, err)
+		}
+
+		backoff := r.policy.backoff(attempt, retryAfter)
+		logging.YLSLogger().Warn("youtube api call failed. retrying",
+			zap.String("operation", op),
+			zap.Int("attempt", attempt),
+			zap.Int("attempts", attempts),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-r.ctx.Done():
+			return fmt.Errorf("%s was cancelled while waiting to retry. %w", op, err)
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (r *retryingBackend) ListBroadcasts(status string) (items []*youtube.LiveBroadcast, err error) {
+	err = r.do("list broadcasts", func() error {
+		items, err = r.backend.ListBroadcasts(status)
+		return err
+	})
+	return items, err
+}
+
+// InsertBroadcast retries the insert, unless the broadcast turns out to have been created by a failed attempt (which
+// is possible when the API fails after handling the request)
+func (r *retryingBackend) InsertBroadcast(parts []string, b *youtube.LiveBroadcast) (inserted *youtube.LiveBroadcast, err error) {
+	key, tagged := OccurrenceKeyOf(b)
+	attempt := 0
+	err = r.do("insert broadcast", func() error {
+		attempt++
+		if attempt > 1 && tagged {
+			if existing := r.findInserted(key); existing != nil {
+				inserted = existing
+				return nil
+			}
+		}
+		inserted, err = r.backend.InsertBroadcast(parts, b)
+		return err
+	})
+	return inserted, err
+}
+
+func (r *retryingBackend) findInserted(key string) *youtube.LiveBroadcast {
+	upcoming, err := r.backend.ListBroadcasts(BROADCAST_STATUS_UPCOMING)
+	if err != nil {
+		return nil
+	}
+	for _, b := range upcoming {
+		if k, ok := OccurrenceKeyOf(b); ok && k == key {
+			logging.YLSLogger().Info("found broadcast created by a failed insert attempt", zap.String("occurrence", key), zap.String("broadcastId", b.Id))
+			return b
+		}
+	}
+	return nil
+}
+
+func (r *retryingBackend) UpdateBroadcast(parts []string, b *youtube.LiveBroadcast) (updated *youtube.LiveBroadcast, err error) {
+	err = r.do("update broadcast", func() error {
+		updated, err = r.backend.UpdateBroadcast(parts, b)
+		return err
+	})
+	return updated, err
+}
+
+func (r *retryingBackend) BindBroadcast(broadcastId, liveStreamId string) (bound *youtube.LiveBroadcast, err error) {
+	err = r.do("bind broadcast", func() error {
+		bound, err = r.backend.BindBroadcast(broadcastId, liveStreamId)
+		return err
+	})
+	return bound, err
+}
+
+func (r *retryingBackend) DeleteBroadcast(broadcastId string) error {
+	return r.do("delete broadcast", func() error {
+		return r.backend.DeleteBroadcast(broadcastId)
+	})
+}
+
+func (r *retryingBackend) TransitionBroadcast(broadcastId, status string) (transitioned *youtube.LiveBroadcast, err error) {
+	err = r.do("transition broadcast", func() error {
+		transitioned, err = r.backend.TransitionBroadcast(broadcastId, status)
+		return err
+	})
+	return transitioned, err
+}
+
+func (r *retryingBackend) ListLiveStreams() (items []*youtube.LiveStream, err error) {
+	err = r.do("list live streams", func() error {
+		items, err = r.backend.ListLiveStreams()
+		return err
+	})
+	return items, err
+}
+
+func (r *retryingBackend) InsertLiveStream(parts []string, ls *youtube.LiveStream) (inserted *youtube.LiveStream, err error) {
+	err = r.do("insert live stream", func() error {
+		inserted, err = r.backend.InsertLiveStream(parts, ls)
+		return err
+	})
+	return inserted, err
+}
+
+// SetThumbnail buffers the image so that it can be uploaded again by each attempt
+func (r *retryingBackend) SetThumbnail(videoId string, media io.Reader) (resp *youtube.ThumbnailSetResponse, err error) {
+	b, err := io.ReadAll(media)
+	if err != nil {
+		return nil, err
+	}
+	err = r.do("set thumbnail", func() error {
+		resp, err = r.backend.SetThumbnail(videoId, bytes.NewReader(b))
+		return err
+	})
+	return resp, err
+}
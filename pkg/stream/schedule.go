@@ -0,0 +1,184 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"google.golang.org/api/youtube/v3"
+)
+
+const OCCURRENCE_TAG_PREFIX = "yls-occurrence: "
+
+const (
+	// MAX_DURATION_RECHECK_INTERVAL is how long to wait between re-checks of an occurrence which has not gone live yet
+	MAX_DURATION_RECHECK_INTERVAL = 10 * time.Minute
+	// MAX_DURATION_LATE_START is how long after its scheduled start a broadcast which has not gone live is waited for
+	MAX_DURATION_LATE_START = 24 * time.Hour
+)
+
+// activationLookbacks are the windows searched (in order) for the most recent activation of a schedule. Small windows are
+// tried first so that frequent schedules do not have to iterate over many activations
+var activationLookbacks = []time.Duration{
+	time.Hour,
+	25 * time.Hour,
+	8 * 24 * time.Hour,
+	32 * 24 * time.Hour,
+	367 * 24 * time.Hour,
+}
+
+// ParseSchedule parses the cron schedule for the stream in the time zone of the stream
+func (s *Stream) ParseSchedule() (cron.Schedule, error) {
+	return s.ParseSpec(s.Schedule)
+}
+
+// ParseSpec parses a cron spec in the time zone of the stream. Specs which already specify a time zone
+// (using a CRON_TZ= or TZ= prefix) are left unchanged
+func (s *Stream) ParseSpec(spec string) (cron.Schedule, error) {
+	if s.Timezone != "" && !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+		spec = fmt.Sprintf("CRON_TZ=%s %s", s.Timezone, spec)
+	}
+	return cron.ParseStandard(spec)
+}
+
+// aheadSchedule activates a fixed duration before each activation of the wrapped schedule
+type aheadSchedule struct {
+	cron.Schedule
+	ahead time.Duration
+}
+
+func (a aheadSchedule) Next(t time.Time) time.Time {
+	next := a.Schedule.Next(t.Add(a.ahead))
+	if next.IsZero() {
+		return next
+	}
+	return next.Add(-a.ahead)
+}
+
+// JobSchedule returns the schedule on which broadcasts for the stream are created. When CreateAhead is configured, the
+// stream schedule describes when each broadcast starts, so the job runs CreateAhead before every start
+func (s *Stream) JobSchedule() (cron.Schedule, error) {
+	sched, err := s.ParseSchedule()
+	if err != nil {
+		return nil, err
+	}
+	if s.CreateAhead.Duration > 0 {
+		return aheadSchedule{Schedule: sched, ahead: s.CreateAhead.Duration}, nil
+	}
+	return sched, nil
+}
+
+// MaxDurationSchedule returns the schedule on which the active broadcasts of the stream are checked against its
+// MaxDurationMinutes. Rather than polling, each occurrence is checked once the maximum duration has passed since its
+// scheduled start. Broadcasts which went live late are checked again by the job itself (see EnforceMaxDuration)
+func (s *Stream) MaxDurationSchedule() (cron.Schedule, error) {
+	sched, err := s.ParseSchedule()
+	if err != nil {
+		return nil, err
+	}
+
+	after := time.Duration(s.MaxDurationMinutes) * time.Minute
+	if s.CreateAhead.Duration <= 0 {
+		after += time.Duration(s.StartDelaySeconds) * time.Second
+	}
+	return aheadSchedule{Schedule: sched, ahead: -after}, nil
+}
+
+// Occurrence determines the slot of the occurrence that a job running at t creates a broadcast for, as well as the
+// scheduled start of that broadcast.
+//
+// By default, jobs run on the stream schedule and each broadcast starts StartDelaySeconds after the job runs.
+// When CreateAhead is configured, the broadcast is created for the latest occurrence of the schedule within
+// CreateAhead of t (or the next occurrence if there are none) and starts at that occurrence
+func (s *Stream) Occurrence(t time.Time) (slot, start time.Time) {
+	if s.CreateAhead.Duration <= 0 {
+		return s.OccurrenceSlot(t), t.Add(time.Duration(s.StartDelaySeconds) * time.Second)
+	}
+
+	sched, err := s.ParseSchedule()
+	if err != nil {
+		return s.OccurrenceSlot(t), t
+	}
+	if prev, ok := previousActivation(sched, t.Add(s.CreateAhead.Duration)); ok && prev.After(t) {
+		return prev, prev
+	}
+	next := sched.Next(t)
+	return next, next
+}
+
+// previousActivation finds the most recent activation of the schedule at or before t
+func previousActivation(sched cron.Schedule, t time.Time) (time.Time, bool) {
+	for _, lookback := range activationLookbacks {
+		var prev time.Time
+		for next := sched.Next(t.Add(-lookback)); !next.IsZero() && !next.After(t); next = sched.Next(next) {
+			prev = next
+		}
+		if !prev.IsZero() {
+			return prev, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// OccurrenceSlot determines which scheduled slot a job running at t belongs to. This is the most recent activation
+// of the stream schedule so that re-running a job (for example after a restart with --now) maps to the same slot.
+// If no activation can be found, t truncated to the minute is used instead
+func (s *Stream) OccurrenceSlot(t time.Time) time.Time {
+	sched, err := s.ParseSchedule()
+	if err == nil {
+		if prev, ok := previousActivation(sched, t); ok {
+			return prev
+		}
+	}
+	return t.Truncate(time.Minute)
+}
+
+// OccurrenceKey uniquely identifies the broadcast for a stream in a given slot. Wall-clock time is used so that a
+// schedule which fires twice around a DST change still maps to a single occurrence
+func (s *Stream) OccurrenceKey(slot time.Time) string {
+	return fmt.Sprintf("%s@%s", s.Name, slot.Format("2006-01-02T15:04"))
+}
+
+// occurrenceTag is appended to broadcast descriptions so that an occurrence can be recognized later
+func occurrenceTag(key string) string {
+	return OCCURRENCE_TAG_PREFIX + key
+}
+
+// withOccurrenceTag appends the occurrence tag for key to a broadcast description
+func withOccurrenceTag(description, key string) string {
+	if description == "" {
+		return occurrenceTag(key)
+	}
+	return fmt.Sprintf("%s\n\n%s", description, occurrenceTag(key))
+}
+
+// OccurrenceKeyOf returns the occurrence key tagged in the description of a broadcast created by YLS, if any
+func OccurrenceKeyOf(b *youtube.LiveBroadcast) (string, bool) {
+	if b == nil || b.Snippet == nil {
+		return "", false
+	}
+	for _, line := range strings.Split(b.Snippet.Description, "\n") {
+		if strings.HasPrefix(line, OCCURRENCE_TAG_PREFIX) {
+			return strings.TrimSpace(strings.TrimPrefix(line, OCCURRENCE_TAG_PREFIX)), true
+		}
+	}
+	return "", false
+}
+
+// matchesOccurrence reports whether an existing broadcast is the broadcast for an occurrence. Broadcasts are matched
+// on the occurrence tag in their description or, for broadcasts that were not tagged, on title and scheduled start
+func matchesOccurrence(b *youtube.LiveBroadcast, key, title string, start time.Time) bool {
+	if k, ok := OccurrenceKeyOf(b); ok {
+		return k == key
+	}
+	if b.Snippet == nil || b.Snippet.Title != title {
+		return false
+	}
+
+	existingStart, err := time.Parse(time.RFC3339, b.Snippet.ScheduledStartTime)
+	if err != nil {
+		return false
+	}
+	return existingStart.Truncate(time.Minute).Equal(start.Truncate(time.Minute))
+}
@@ -0,0 +1,154 @@
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxDurationSchedule(t *testing.T) {
+	s := &Stream{Name: "sunday-service", Schedule: "0 9 * * 0", Timezone: "UTC", StartDelaySeconds: 300, MaxDurationMinutes: 120}
+	sched, err := s.MaxDurationSchedule()
+	if err != nil {
+		t.Fatalf("failed to parse max duration schedule. %s", err)
+	}
+
+	// the broadcast created at 9:00 starts at 9:05 and reaches its maximum duration at 11:05
+	start := time.Date(2023, 3, 5, 8, 0, 0, 0, time.UTC)
+	want := []time.Time{
+		time.Date(2023, 3, 5, 11, 5, 0, 0, time.UTC),
+		time.Date(2023, 3, 12, 11, 5, 0, 0, time.UTC),
+	}
+
+	next := start
+	for i, w := range want {
+		next = sched.Next(next)
+		if !next.Equal(w) {
+			t.Fatalf("expected activation %d at %s, got %s", i, w, next)
+		}
+	}
+}
+
+func TestMaxDurationScheduleCreateAhead(t *testing.T) {
+	s := &Stream{Name: "sunday-service", Schedule: "0 9 * * 0", Timezone: "UTC", StartDelaySeconds: 300, MaxDurationMinutes: 90}
+	s.CreateAhead.Duration = 24 * time.Hour
+	sched, err := s.MaxDurationSchedule()
+	if err != nil {
+		t.Fatalf("failed to parse max duration schedule. %s", err)
+	}
+
+	// broadcasts created ahead start on the schedule itself
+	want := time.Date(2023, 3, 5, 10, 30, 0, 0, time.UTC)
+	if next := sched.Next(time.Date(2023, 3, 4, 9, 0, 0, 0, time.UTC)); !next.Equal(want) {
+		t.Errorf("expected the first check at %s, got %s", want, next)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "7d", want: 7 * 24 * time.Hour},
+		{value: "1d12h", want: 36 * time.Hour},
+		{value: " 2d ", want: 48 * time.Hour},
+		{value: "90m", want: 90 * time.Minute},
+		{value: "1d30m", want: 24*time.Hour + 30*time.Minute},
+		{value: "1.5d", wantErr: true},
+		{value: "7days", wantErr: true},
+		{value: "d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %t, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJobScheduleCreateAhead(t *testing.T) {
+	s := &Stream{Name: "sunday-service", Schedule: "CRON_TZ=UTC 0 9 * * 0"}
+	s.CreateAhead.Duration = 36 * time.Hour
+	sched, err := s.JobSchedule()
+	if err != nil {
+		t.Fatalf("failed to parse job schedule. %s", err)
+	}
+
+	// each job runs exactly 1d12h before the start of its occurrence, which it creates
+	want := []time.Time{
+		time.Date(2023, 3, 3, 21, 0, 0, 0, time.UTC),
+		time.Date(2023, 3, 10, 21, 0, 0, 0, time.UTC),
+	}
+	next := time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, w := range want {
+		next = sched.Next(next)
+		if !next.Equal(w) {
+			t.Fatalf("expected activation %d at %s, got %s", i, w, next)
+		}
+		slot, start := s.Occurrence(next)
+		if wantStart := w.Add(s.CreateAhead.Duration); !slot.Equal(wantStart) || !start.Equal(wantStart) {
+			t.Errorf("expected the job at %s to create the occurrence starting at %s, got slot %s starting at %s", w, wantStart, slot, start)
+		}
+	}
+}
+
+func TestOccurrenceCreateAhead(t *testing.T) {
+	// broadcasts start on Sundays and Wednesdays, and are created 1d12h ahead
+	s := &Stream{Name: "services", Schedule: "CRON_TZ=UTC 0 9 * * 0,3"}
+	s.CreateAhead.Duration = 36 * time.Hour
+	sunday := time.Date(2023, 3, 5, 9, 0, 0, 0, time.UTC)
+	wednesday := time.Date(2023, 3, 8, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{name: "start of the window", now: sunday.Add(-36 * time.Hour), want: sunday},
+		{name: "inside the window", now: time.Date(2023, 3, 4, 12, 0, 0, 0, time.UTC), want: sunday},
+		{name: "just before the start", now: sunday.Add(-time.Minute), want: sunday},
+		{name: "inside the next window", now: time.Date(2023, 3, 6, 22, 0, 0, 0, time.UTC), want: wednesday},
+		{name: "between windows", now: time.Date(2023, 3, 5, 12, 0, 0, 0, time.UTC), want: wednesday},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot, start := s.Occurrence(tt.now)
+			if !slot.Equal(tt.want) || !start.Equal(tt.want) {
+				t.Errorf("expected the occurrence starting at %s, got slot %s starting at %s", tt.want, slot, start)
+			}
+		})
+	}
+}
+
+func TestOccurrenceCreateAheadAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Skipf("time zone data is not available. %s", err)
+	}
+	s := &Stream{Name: "sunday-service", Schedule: "CRON_TZ=America/Toronto 0 9 * * 0"}
+	s.CreateAhead.Duration = 7 * 24 * time.Hour
+	sched, err := s.JobSchedule()
+	if err != nil {
+		t.Fatalf("failed to parse job schedule. %s", err)
+	}
+
+	// daylight saving time starts on March 12, so the job 7 days (168 hours) ahead of 9:00 EDT runs at 8:00 EST
+	start := time.Date(2023, 3, 12, 9, 0, 0, 0, loc)
+	next := sched.Next(time.Date(2023, 3, 1, 0, 0, 0, 0, loc))
+	if want := time.Date(2023, 3, 5, 8, 0, 0, 0, loc); !next.Equal(want) {
+		t.Fatalf("expected the job at %s, got %s", want, next)
+	}
+
+	slot, scheduledStart := s.Occurrence(next)
+	if !slot.Equal(start) || !scheduledStart.Equal(start) {
+		t.Errorf("expected the occurrence starting at %s, got slot %s starting at %s", start, slot, scheduledStart)
+	}
+	if key := s.OccurrenceKey(slot.In(loc)); key != "sunday-service@2023-03-12T09:00" {
+		t.Errorf("expected the occurrence key in wall-clock time, got %q", key)
+	}
+}
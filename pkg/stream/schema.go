@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"reflect"
+	"sync"
+
+	"sykesdev.ca/yls/pkg/client"
+	"sykesdev.ca/yls/pkg/pub"
+	"sykesdev.ca/yls/pkg/schema"
+)
+
+const SCHEMA_ID = "https://sykesdev.ca/yls/streams.schema.json"
+
+// registerPublishersSchema registers the schema of the publishers of a stream the first time the schema is generated,
+// since publishers may be registered until then
+var registerPublishersSchema sync.Once
+
+// Schema generates the JSON Schema of the streams configuration file. The annotations it is generated from are also
+// used by Validate to check enums and required fields
+func Schema() *schema.JSONSchema {
+	registerPublishersSchema.Do(func() {
+		schema.RegisterType(reflect.TypeOf(pub.Publishers{}), pub.Schema())
+	})
+
+	s := schema.Generate(reflect.TypeOf(StreamList{}))
+	s.Schema = schema.JSON_SCHEMA_DRAFT
+	s.ID = SCHEMA_ID
+	s.Title = "YLS streams configuration"
+	s.Description = "Configuration of the Youtube Live Broadcasts scheduled by YLS"
+	return s
+}
+
+func init() {
+	schema.RegisterType(reflect.TypeOf(Duration{}), &schema.JSONSchema{
+		Type:    "string",
+		Pattern: `^(\d+d)?((\d+(\.\d+)?(ns|us|µs|ms|s|m|h))+)?$`,
+	})
+
+	schema.Annotate(reflect.TypeOf(StreamList{}), map[string]schema.Field{
+		"timezone": {Description: "IANA time zone used for the schedules of every stream that does not set its own. Defaults to the local time zone of the process", Examples: []interface{}{"America/Toronto"}},
+		"accounts": {Description: "YouTube channels and the credentials used to manage them. Streams without an account use the credentials given on the command line"},
+		"streams":  {Description: "The streams to schedule broadcasts for", Required: true},
+	})
+
+	schema.Annotate(reflect.TypeOf(Account{}), map[string]schema.Field{
+		"name":         {Description: "Unique name of the account, referenced by the account of streams", Required: true},
+		"oauthConfig":  {Description: "Path to the Google OAuth2.0 client secret file used to log in to the account"},
+		"credentials":  {Description: "Path to a GOOGLE_APPLICATION_CREDENTIALS-style file (such as authorized_user credentials) used instead of oauthConfig"},
+		"tokenStore":   {Description: "Where the OAuth2.0 token of the account is cached. Defaults to --token-store", Enum: client.TOKEN_STORES_ALLOWED},
+		"secretsCache": {Description: "Path of the token cache of the account. Defaults to the --secrets-cache path suffixed with the account name"},
+	})
+
+	schema.Annotate(reflect.TypeOf(Stream{}), map[string]schema.Field{
+		"name":               {Description: "Unique name of the stream", Required: true},
+		"account":            {Description: "Name of the account the broadcasts of the stream are created in. Defaults to the credentials given on the command line"},
+		"title":              {Description: "Title of each broadcast. Rendered as a Go template with sprig functions", Required: true, Examples: []interface{}{`Sunday Service – {{ .Start | date "Jan 2" }}`}},
+		"description":        {Description: "Description of each broadcast. Rendered as a Go template with sprig functions"},
+		"thumbnails":         {Description: "Thumbnail images uploaded for each broadcast"},
+		"schedule":           {Description: "Cron schedule on which broadcasts are created or, when createAhead is set, on which broadcasts start", Required: true, Examples: []interface{}{"0 10 * * 0"}},
+		"delaySeconds":       {Description: "Seconds between the creation of a broadcast and its scheduled start. Ignored when createAhead is set"},
+		"createAhead":        {Description: "How long before each scheduled start to create its broadcast (e.g. 7d, 36h). When set, schedule describes when broadcasts start", Examples: []interface{}{"7d"}},
+		"timezone":           {Description: "IANA time zone of the schedules of this stream. Overrides the top-level timezone", Examples: []interface{}{"America/Toronto"}},
+		"endSchedule":        {Description: "Cron schedule on which any active broadcast of the stream is completed"},
+		"maxDurationMinutes": {Description: "Complete active broadcasts of the stream that have been live for longer than this many minutes"},
+		"privacy":            {Description: "Privacy settings of each broadcast"},
+		"contentDetails":     {Description: "Content details of each broadcast, such as whether it can be embedded or is archived after it ends"},
+		"liveStream":         {Description: "The live stream (stream key) each broadcast is bound to"},
+		"publisher":          {Description: "Where each broadcast is published once it has been created. A single publisher may also be given as a mapping"},
+		"publishConcurrency": {Description: "How many publishers of the stream run at the same time. Publishers run one after the other by default"},
+		"vars":               {Description: "User-defined variables available to templates as .Vars"},
+	})
+
+	schema.Annotate(reflect.TypeOf(StreamPrivacy{}), map[string]schema.Field{
+		"level":                   {Description: "Privacy status of each broadcast", Enum: PRIVACY_LEVELS_ALLOWED},
+		"selfDeclaredMadeForKids": {Description: "Declares that each broadcast is made for kids"},
+	})
+
+	schema.Annotate(reflect.TypeOf(StreamContentDetailsConfig{}), map[string]schema.Field{
+		"closedCaptionsType":      {Description: "How closed captions are provided", Enum: CLOSED_CAPTIONS_TYPES_ALLOWED},
+		"enableAutoStart":         {Description: "Start the broadcast automatically when its live stream starts"},
+		"enableAutoStop":          {Description: "Stop the broadcast automatically when its live stream stops"},
+		"enableClosedCaptions":    {Description: "Enable closed captions (deprecated by YouTube in favour of closedCaptionsType)"},
+		"enableContentEncryption": {Description: "Enable content encryption"},
+		"enableDvr":               {Description: "Allow viewers to pause and rewind the broadcast while it is live"},
+		"enableEmbed":             {Description: "Allow the broadcast to be played in embedded players"},
+		"enableLowLatency":        {Description: "Optimize the broadcast for low latency (deprecated by YouTube in favour of latencyPreference)"},
+		"latencyPreference":       {Description: "Latency of the broadcast", Enum: LATENCY_PREFERENCES_ALLOWED},
+		"mesh":                    {Description: "Base64 encoded mesh for 360 degree projections"},
+		"projection":              {Description: "Projection format of the broadcast", Enum: PROJECTIONS_ALLOWED},
+		"recordFromStart":         {Description: "Record the broadcast so that it is archived once it ends"},
+		"startWithSlate":          {Description: "Show a slate when the broadcast starts"},
+		"stereoLayout":            {Description: "3D stereo layout of the broadcast", Enum: STEREO_LAYOUTS_ALLOWED},
+	})
+
+	schema.Annotate(reflect.TypeOf(StreamLiveStreamConfig{}), map[string]schema.Field{
+		"streamKey": {Description: "Stream key (ingestion stream name) of an existing live stream"},
+		"title":     {Description: "Title of an existing live stream, or of the live stream to create"},
+		"create":    {Description: "Create a reusable live stream with these settings when none matches"},
+	})
+
+	schema.Annotate(reflect.TypeOf(StreamLiveStreamCreateConfig{}), map[string]schema.Field{
+		"description":   {Description: "Description of the created live stream"},
+		"resolution":    {Description: "Resolution of the inbound video", Enum: LIVESTREAM_RESOLUTIONS_ALLOWED},
+		"frameRate":     {Description: "Frame rate of the inbound video", Enum: LIVESTREAM_FRAME_RATES_ALLOWED},
+		"ingestionType": {Description: "Protocol used to send video to YouTube", Enum: LIVESTREAM_INGESTION_TYPES_ALLOWED},
+	})
+
+	schema.Annotate(reflect.TypeOf(StreamThumbnailDetailsConfig{}), map[string]schema.Field{
+		"default":  {Description: "Default thumbnail"},
+		"high":     {Description: "High resolution thumbnail"},
+		"maxres":   {Description: "Maximum resolution thumbnail"},
+		"medium":   {Description: "Medium resolution thumbnail"},
+		"standard": {Description: "Standard resolution thumbnail"},
+	})
+
+	schema.Annotate(reflect.TypeOf(StreamThumbnailConfig{}), map[string]schema.Field{
+		"width":  {Description: "Width of the thumbnail in pixels"},
+		"height": {Description: "Height of the thumbnail in pixels"},
+		"path":   {Description: "Path to the thumbnail image (at most 2MB). Rendered as a Go template with sprig functions", Required: true},
+	})
+}
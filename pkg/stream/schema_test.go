@@ -0,0 +1,34 @@
+package stream
+
+import (
+	"testing"
+
+	"sykesdev.ca/yls/pkg/pub"
+)
+
+func TestSchemaAllowsSinglePublisherOrList(t *testing.T) {
+	s := Schema()
+	publisher := s.Properties["streams"].Items.Properties["publisher"]
+	if publisher == nil {
+		t.Fatal("expected the schema of streams to describe publisher")
+	}
+	if publisher.Description == "" {
+		t.Error("expected the publisher annotation to be kept")
+	}
+	if len(publisher.OneOf) != 2 {
+		t.Fatalf("expected publisher to be one of a mapping or a list, got %+v", publisher)
+	}
+
+	single, list := publisher.OneOf[0], publisher.OneOf[1]
+	if single.Type != "object" {
+		t.Errorf("expected a single publisher to be an object, got %q", single.Type)
+	}
+	if list.Type != "array" || list.Items == nil || list.Items.Type != "object" {
+		t.Errorf("expected a list of publisher objects, got %+v", list)
+	}
+	for _, name := range pub.Types() {
+		if single.Properties[name] == nil {
+			t.Errorf("expected the configuration of publisher type %s to be described", name)
+		}
+	}
+}
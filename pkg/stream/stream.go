@@ -2,27 +2,86 @@ package stream
 
 import (
 	"fmt"
+	"time"
 
 	"google.golang.org/api/youtube/v3"
+	"sykesdev.ca/yls/pkg/helper"
 	"sykesdev.ca/yls/pkg/pub"
 )
 
 type StreamList struct {
-	Items []Stream `yaml:"streams"`
+	Timezone string    `yaml:"timezone,omitempty"`
+	Accounts []Account `yaml:"accounts,omitempty"`
+	Items    []Stream  `yaml:"streams"`
+}
+
+// Account is a YouTube channel and the credentials used to manage it. Streams without an account use the credentials
+// given on the command line
+type Account struct {
+	Name         string `yaml:"name"`
+	OauthConfig  string `yaml:"oauthConfig,omitempty"`
+	Credentials  string `yaml:"credentials,omitempty"`
+	TokenStore   string `yaml:"tokenStore,omitempty"`
+	SecretsCache string `yaml:"secretsCache,omitempty"`
+}
+
+// ApplyDefaults propagates list-level defaults (such as the time zone) to streams that do not override them
+func (sl *StreamList) ApplyDefaults() {
+	for i := range sl.Items {
+		if sl.Items[i].Timezone == "" {
+			sl.Items[i].Timezone = sl.Timezone
+		}
+	}
+}
+
+// Location resolves the list-level time zone. The process-local time zone is used if none is configured
+func (sl *StreamList) Location() (*time.Location, error) {
+	return loadLocation(sl.Timezone)
+}
+
+// Find returns the stream with the given name, or nil if no such stream is configured
+func (sl *StreamList) Find(name string) *Stream {
+	for i := range sl.Items {
+		if sl.Items[i].Name == name {
+			return &sl.Items[i]
+		}
+	}
+	return nil
+}
+
+// FindAccount returns the account with the given name, or nil if no such account is configured. The empty name is the
+// default account given on the command line, which is also nil
+func (sl *StreamList) FindAccount(name string) *Account {
+	for i := range sl.Accounts {
+		if sl.Accounts[i].Name == name && name != "" {
+			return &sl.Accounts[i]
+		}
+	}
+	return nil
 }
 
 type Stream struct {
-	Name              string                       `yaml:"name"`
-	Title             string                       `yaml:"title"`
-	Thumbnail         StreamThumbnailDetailsConfig `yaml:"thumbnails,omitempty"`
-	Description       string                       `yaml:"description"`
-	Schedule          string                       `yaml:"schedule"`
-	StartDelaySeconds uint16                       `yaml:"delaySeconds"`
-	Privacy           StreamPrivacy                `yaml:"privacy,omitempty"`
-	ContentDetails    StreamContentDetailsConfig   `yaml:"contentDetails,omitempty"`
-	Publisher         *pub.PublisherConfig         `yaml:"publisher,omitempty"`
+	Name               string                       `yaml:"name"`
+	Account            string                       `yaml:"account,omitempty"`
+	Title              string                       `yaml:"title"`
+	Thumbnail          StreamThumbnailDetailsConfig `yaml:"thumbnails,omitempty"`
+	Description        string                       `yaml:"description"`
+	Schedule           string                       `yaml:"schedule"`
+	Timezone           string                       `yaml:"timezone,omitempty"`
+	StartDelaySeconds  uint16                       `yaml:"delaySeconds"`
+	CreateAhead        Duration                     `yaml:"createAhead,omitempty"`
+	EndSchedule        string                       `yaml:"endSchedule,omitempty"`
+	MaxDurationMinutes uint                         `yaml:"maxDurationMinutes,omitempty"`
+	Privacy            StreamPrivacy                `yaml:"privacy,omitempty"`
+	ContentDetails     StreamContentDetailsConfig   `yaml:"contentDetails,omitempty"`
+	LiveStream         *StreamLiveStreamConfig      `yaml:"liveStream,omitempty"`
+	Publishers         pub.Publishers               `yaml:"publisher,omitempty"`
+	PublishConcurrency uint                         `yaml:"publishConcurrency,omitempty"`
+	Vars               map[string]interface{}       `yaml:"vars,omitempty"`
 }
 
+var PRIVACY_LEVELS_ALLOWED = []string{"private", "unlisted", "public"}
+
 type StreamPrivacy struct {
 	Level                   string `yaml:"level,omitempty"`
 	SelfDeclaredMadeForKids bool   `yaml:"selfDeclaredMadeForKids,omitempty"`
@@ -45,6 +104,13 @@ type StreamContentDetailsConfig struct {
 	StereoLayout            string `yaml:"stereoLayout,omitempty"`
 }
 
+var (
+	CLOSED_CAPTIONS_TYPES_ALLOWED = []string{"closedCaptionsTypeUnspecified", "closedCaptionsDisabled", "closedCaptionsHttpPost", "closedCaptionsEmbedded"}
+	LATENCY_PREFERENCES_ALLOWED   = []string{"normal", "low", "ultraLow"}
+	PROJECTIONS_ALLOWED           = []string{"rectangular", "360"}
+	STEREO_LAYOUTS_ALLOWED        = []string{"mono", "leftRight", "topBottom"}
+)
+
 func (cd *StreamContentDetailsConfig) Make() *youtube.LiveBroadcastContentDetails {
 	return &youtube.LiveBroadcastContentDetails{
 		ClosedCaptionsType:      cd.ClosedCaptionsType,
@@ -64,6 +130,92 @@ func (cd *StreamContentDetailsConfig) Make() *youtube.LiveBroadcastContentDetail
 	}
 }
 
+// Location resolves the time zone of the stream. The process-local time zone is used if none is configured
+func (s *Stream) Location() (*time.Location, error) {
+	return loadLocation(s.Timezone)
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// StreamLiveStreamConfig identifies the LiveStream (stream key) that a scheduled broadcast will be bound to.
+// An existing LiveStream is matched on its stream key or title. When Create is specified and no existing LiveStream
+// matches, a new reusable LiveStream is created using Title and the provided ingestion settings.
+type StreamLiveStreamConfig struct {
+	StreamKey string                        `yaml:"streamKey,omitempty"`
+	Title     string                        `yaml:"title,omitempty"`
+	Create    *StreamLiveStreamCreateConfig `yaml:"create,omitempty"`
+}
+
+type StreamLiveStreamCreateConfig struct {
+	Description   string `yaml:"description,omitempty"`
+	Resolution    string `yaml:"resolution,omitempty"`
+	FrameRate     string `yaml:"frameRate,omitempty"`
+	IngestionType string `yaml:"ingestionType,omitempty"`
+}
+
+var (
+	LIVESTREAM_RESOLUTIONS_ALLOWED     = []string{"240p", "360p", "480p", "720p", "1080p", "1440p", "2160p", "variable"}
+	LIVESTREAM_FRAME_RATES_ALLOWED     = []string{"30fps", "60fps", "variable"}
+	LIVESTREAM_INGESTION_TYPES_ALLOWED = []string{"rtmp", "dash", "webrtc", "hls"}
+)
+
+const (
+	LIVESTREAM_DEFAULT_RESOLUTION     = "variable"
+	LIVESTREAM_DEFAULT_FRAME_RATE     = "variable"
+	LIVESTREAM_DEFAULT_INGESTION_TYPE = "rtmp"
+)
+
+// Matches reports whether an existing LiveStream resource is the one described by this configuration
+func (lc *StreamLiveStreamConfig) Matches(ls *youtube.LiveStream) bool {
+	if ls == nil {
+		return false
+	}
+	if lc.StreamKey != "" {
+		return ls.Cdn != nil && ls.Cdn.IngestionInfo != nil && ls.Cdn.IngestionInfo.StreamName == lc.StreamKey
+	}
+	if lc.Title != "" {
+		return ls.Snippet != nil && ls.Snippet.Title == lc.Title
+	}
+	return false
+}
+
+func (lc *StreamLiveStreamConfig) Make() *youtube.LiveStream {
+	cc := lc.Create
+	if cc == nil {
+		cc = &StreamLiveStreamCreateConfig{}
+	}
+
+	return &youtube.LiveStream{
+		Snippet: &youtube.LiveStreamSnippet{
+			Title:       lc.Title,
+			Description: cc.Description,
+		},
+		Cdn: &youtube.CdnSettings{
+			Resolution:    helper.DefaultValue(cc.Resolution, LIVESTREAM_DEFAULT_RESOLUTION, ""),
+			FrameRate:     helper.DefaultValue(cc.FrameRate, LIVESTREAM_DEFAULT_FRAME_RATE, ""),
+			IngestionType: helper.DefaultValue(cc.IngestionType, LIVESTREAM_DEFAULT_INGESTION_TYPE, ""),
+		},
+		ContentDetails: &youtube.LiveStreamContentDetails{
+			IsReusable: true,
+		},
+	}
+}
+
+func (lc *StreamLiveStreamConfig) String() string {
+	if lc == nil {
+		return ""
+	}
+	if lc.StreamKey != "" {
+		return lc.StreamKey
+	}
+	return lc.Title
+}
+
 type StreamThumbnailDetailsConfig struct {
 	Default  StreamThumbnailConfig `yaml:"default,omitempty"`
 	High     StreamThumbnailConfig `yaml:"high,omitempty"`
@@ -72,6 +224,33 @@ type StreamThumbnailDetailsConfig struct {
 	Standard StreamThumbnailConfig `yaml:"standard,omitempty"`
 }
 
+// namedField points to a string field of the configuration along with its YAML name
+type namedField struct {
+	name  string
+	value *string
+}
+
+// paths returns a pointer to the path of each thumbnail size along with the YAML name of the size
+func (t *StreamThumbnailDetailsConfig) paths() []namedField {
+	return []namedField{
+		{"default", &t.Default.Path},
+		{"high", &t.High.Path},
+		{"maxres", &t.Maxres.Path},
+		{"medium", &t.Medium.Path},
+		{"standard", &t.Standard.Path},
+	}
+}
+
+// configured reports whether a thumbnail is configured for any size
+func (t *StreamThumbnailDetailsConfig) configured() bool {
+	for _, f := range t.paths() {
+		if *f.value != "" {
+			return true
+		}
+	}
+	return false
+}
+
 type StreamThumbnailConfig struct {
 	Width  int64  `yaml:"width,omitempty"`
 	Height int64  `yaml:"height,omitempty"`
@@ -83,13 +262,13 @@ type StreamThumbnail struct {
 }
 
 func (s *Stream) String() string {
-	return fmt.Sprintf("{'name': %s, 'title': %s, 'description': %s, 'schedule': %s, 'startDelay': %q seconds, 'privacyLevel': %s, 'publisherName': %s}",
+	return fmt.Sprintf("{'name': %s, 'title': %s, 'description': %s, 'schedule': %s, 'startDelay': %q seconds, 'privacyLevel': %s, 'publishers': [%s]}",
 		s.Name,
 		s.Title,
 		s.Description,
 		s.Schedule,
 		s.StartDelaySeconds,
 		s.Privacy.Level,
-		s.Publisher,
+		s.Publishers,
 	)
 }
@@ -0,0 +1,61 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig"
+)
+
+// TemplateVars are the inputs available when rendering the templated fields of a stream
+// (title, description and thumbnail paths). For example: "Sunday Service – {{ .Start | date "Jan 2" }}"
+type TemplateVars struct {
+	// Name is the name of the stream
+	Name string
+	// Start is the scheduled start of the broadcast
+	Start time.Time
+	// Occurrence is the 1-based number of the broadcast among those created for the stream (tracked in the state store)
+	Occurrence int
+	// Vars are the user-defined variables configured for the stream
+	Vars map[string]interface{}
+}
+
+func renderTemplate(name, text string, vars *TemplateVars) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+
+	tmpl, err := template.New(name).Funcs(sprig.TxtFuncMap()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var res bytes.Buffer
+	if err := tmpl.Execute(&res, vars); err != nil {
+		return "", err
+	}
+	return res.String(), nil
+}
+
+// Render returns a copy of the stream with its title, description and thumbnail paths rendered as templates
+func (s *Stream) Render(vars *TemplateVars) (*Stream, error) {
+	rendered := *s
+	fields := []namedField{
+		{"title", &rendered.Title},
+		{"description", &rendered.Description},
+	}
+	for _, t := range rendered.Thumbnail.paths() {
+		fields = append(fields, namedField{"thumbnails." + t.name + ".path", t.value})
+	}
+
+	for _, f := range fields {
+		value, err := renderTemplate(f.name, *f.value, vars)
+		if err != nil {
+			return nil, fmt.Errorf("unable to render %s for stream %s. %w", f.name, s.Name, err)
+		}
+		*f.value = value
+	}
+	return &rendered, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -13,48 +15,114 @@ import (
 	"google.golang.org/api/youtube/v3"
 	"sykesdev.ca/yls/pkg/client"
 	"sykesdev.ca/yls/pkg/logging"
+	"sykesdev.ca/yls/pkg/pub"
+	"sykesdev.ca/yls/pkg/state"
 )
 
 type StreamUploaderConfig struct {
 	Context     context.Context
 	OauthConfig string
-	Cache       string
-	Scopes      []string
-	DryRunMode  bool
+	TokenStore  client.TokenStore
+	LoginMode   string
+	// Credentials is a GOOGLE_APPLICATION_CREDENTIALS-style file used instead of OauthConfig and TokenStore
+	Credentials string
+	// NonInteractive fails instead of asking the user to log in when there is no usable token
+	NonInteractive bool
+	Scopes         []string
+	DryRunMode     bool
+	// Backend overrides the YouTube Data API backend. When set, no OAuth2.0 configuration is required
+	Backend BroadcastBackend
+	// State records every broadcast created by the client. A nil State disables recording
+	State *state.Store
+	// Retry configures how failed YouTube Data API calls are retried. DefaultRetryPolicy is used when it is nil
+	Retry *RetryPolicy
 }
 
 type StreamUploadClient struct {
-	svc    *youtube.Service
-	dryRun bool
+	backend BroadcastBackend
+	state   *state.Store
+	retry   RetryPolicy
+	dryRun  bool
+
+	mu sync.Mutex
+	// reattempts are the pending re-attempts of jobs which failed because the API quota was exhausted, by stream name
+	reattempts map[string]*reattempt
+	// maxDurationChecks are the pending re-checks of broadcasts which have not reached their maximum duration yet, by
+	// stream name
+	maxDurationChecks map[string]*reattempt
+	// afterFunc schedules re-attempts and re-checks. time.AfterFunc is used when it is nil
+	afterFunc func(d time.Duration, f func()) (stop func() bool)
+}
+
+// reattempt is a pending re-attempt or re-check of a job
+type reattempt struct {
+	stop func() bool
 }
 
 func New(cfg *StreamUploaderConfig) (*StreamUploadClient, error) {
-	if cfg.OauthConfig == "" {
-		return nil, errors.New("oauth configuration file is required. specify --oauth-config")
+	retry := DefaultRetryPolicy
+	if cfg.Retry != nil {
+		retry = *cfg.Retry
 	}
-	c, err := os.ReadFile(cfg.OauthConfig)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read the provided Oauth2 configuration file. %e", err)
+
+	if cfg.Backend != nil {
+		return &StreamUploadClient{
+			backend: WithRetries(cfg.Context, cfg.Backend, retry),
+			state:   cfg.State,
+			retry:   retry,
+			dryRun:  cfg.DryRunMode,
+
+			reattempts:        map[string]*reattempt{},
+			maxDurationChecks: map[string]*reattempt{},
+		}, nil
 	}
 
-	config, err := google.ConfigFromJSON(c, cfg.Scopes...)
+	httpClient, err := newHTTPClient(cfg)
 	if err != nil {
 		return nil, err
 	}
-
-	client := client.Get(cfg.Context, cfg.Cache, config)
-	svc, err := youtube.NewService(cfg.Context, option.WithHTTPClient(client))
+	svc, err := youtube.NewService(cfg.Context, option.WithHTTPClient(httpClient))
 	if err != nil {
 		return nil, err
 	}
 
 	return &StreamUploadClient{
-		svc:    svc,
-		dryRun: cfg.DryRunMode,
+		backend: WithRetries(cfg.Context, NewYoutubeBackend(cfg.Context, svc), retry),
+		state:   cfg.State,
+		retry:   retry,
+		dryRun:  cfg.DryRunMode,
+
+		reattempts:        map[string]*reattempt{},
+		maxDurationChecks: map[string]*reattempt{},
 	}, nil
 }
 
-func (u *StreamUploadClient) uploadThumbnail(s *youtube.ThumbnailsService, videoId, thumbnailPath string) (*youtube.ThumbnailSetResponse, error) {
+func newHTTPClient(cfg *StreamUploaderConfig) (*http.Client, error) {
+	if cfg.Credentials != "" {
+		return client.FromCredentials(cfg.Context, cfg.Credentials, cfg.Scopes...)
+	}
+
+	if cfg.OauthConfig == "" {
+		return nil, errors.New("oauth configuration file is required. specify --oauth-config or --credentials")
+	}
+	c, err := os.ReadFile(cfg.OauthConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the provided Oauth2 configuration file. %w", err)
+	}
+
+	config, err := google.ConfigFromJSON(c, cfg.Scopes...)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Get(cfg.Context, config, &client.Options{
+		Store:          cfg.TokenStore,
+		LoginMode:      cfg.LoginMode,
+		NonInteractive: cfg.NonInteractive,
+	})
+}
+
+func (u *StreamUploadClient) uploadThumbnail(videoId, thumbnailPath string) (*youtube.ThumbnailSetResponse, error) {
 	f, err := os.Open(thumbnailPath)
 	if err != nil {
 		return nil, err
@@ -62,9 +130,7 @@ func (u *StreamUploadClient) uploadThumbnail(s *youtube.ThumbnailsService, video
 	defer f.Close()
 
 	logging.YLSLogger().Debug("thumbnail being uploaded", zap.String("path", thumbnailPath))
-	ts := s.Set(videoId)
-
-	resp, err := ts.Media(f).Do()
+	resp, err := u.backend.SetThumbnail(videoId, f)
 	if err != nil {
 		return nil, err
 	}
@@ -74,8 +140,11 @@ func (u *StreamUploadClient) uploadThumbnail(s *youtube.ThumbnailsService, video
 	return resp, nil
 }
 
-func (u *StreamUploadClient) prepareThumbnails(s *Stream, b *youtube.LiveBroadcast) *youtube.ThumbnailDetails {
+// prepareThumbnails uploads the configured thumbnails of the stream to the broadcast. Thumbnails which could not be
+// uploaded are left out of the returned details and reported in the returned error
+func (u *StreamUploadClient) prepareThumbnails(s *Stream, b *youtube.LiveBroadcast) (*youtube.ThumbnailDetails, error) {
 	var err error
+	var errs []error
 
 	const T_SET_DEFAULT = "default"
 	const T_SET_STANDARD = "standard"
@@ -85,7 +154,6 @@ func (u *StreamUploadClient) prepareThumbnails(s *Stream, b *youtube.LiveBroadca
 
 	tSetResponses := make(map[string]*youtube.ThumbnailSetResponse, 5)
 	videoId := b.Id
-	thumbnailSvc := youtube.NewThumbnailsService(u.svc)
 
 	// defaultUrl is a helper to create a consistent format for the returned ThumbnailDetails struct
 	defaultUrl := func(r *youtube.ThumbnailSetResponse) string {
@@ -99,61 +167,66 @@ func (u *StreamUploadClient) prepareThumbnails(s *Stream, b *youtube.LiveBroadca
 
 	// Default
 	if s.Thumbnail.Default.Path != "" {
-		tSetResponses[T_SET_DEFAULT], err = u.uploadThumbnail(thumbnailSvc, videoId, s.Thumbnail.Default.Path)
+		tSetResponses[T_SET_DEFAULT], err = u.uploadThumbnail(videoId, s.Thumbnail.Default.Path)
 		if err != nil {
 			logging.YLSLogger().Error("unable to upload thumbnail for live broadcast",
 				zap.String("broadcastId", videoId),
 				zap.String("thumbnail_type", T_SET_DEFAULT),
 				zap.Error(err),
 			)
+			errs = append(errs, fmt.Errorf("unable to upload %s thumbnail. %w", T_SET_DEFAULT, err))
 		}
 	}
 
 	// Standard
 	if s.Thumbnail.Standard.Path != "" {
-		tSetResponses[T_SET_STANDARD], err = u.uploadThumbnail(thumbnailSvc, videoId, s.Thumbnail.Standard.Path)
+		tSetResponses[T_SET_STANDARD], err = u.uploadThumbnail(videoId, s.Thumbnail.Standard.Path)
 		if err != nil {
 			logging.YLSLogger().Error("unable to upload thumbnail for live broadcast",
 				zap.String("broadcastId", videoId),
 				zap.String("thumbnail_type", T_SET_STANDARD),
 				zap.Error(err),
 			)
+			errs = append(errs, fmt.Errorf("unable to upload %s thumbnail. %w", T_SET_STANDARD, err))
 		}
 	}
 
 	// Medium
 	if s.Thumbnail.Medium.Path != "" {
-		tSetResponses[T_SET_MEDIUM], err = u.uploadThumbnail(thumbnailSvc, videoId, s.Thumbnail.Medium.Path)
+		tSetResponses[T_SET_MEDIUM], err = u.uploadThumbnail(videoId, s.Thumbnail.Medium.Path)
 		if err != nil {
 			logging.YLSLogger().Error("unable to upload thumbnail for live broadcast",
 				zap.String("broadcastId", videoId),
 				zap.String("thumbnail_type", T_SET_MEDIUM),
 				zap.Error(err),
 			)
+			errs = append(errs, fmt.Errorf("unable to upload %s thumbnail. %w", T_SET_MEDIUM, err))
 		}
 	}
 
 	// High
 	if s.Thumbnail.High.Path != "" {
-		tSetResponses[T_SET_HIGH], err = u.uploadThumbnail(thumbnailSvc, videoId, s.Thumbnail.High.Path)
+		tSetResponses[T_SET_HIGH], err = u.uploadThumbnail(videoId, s.Thumbnail.High.Path)
 		if err != nil {
 			logging.YLSLogger().Error("unable to upload thumbnail for live broadcast",
 				zap.String("broadcastId", videoId),
 				zap.String("thumbnail_type", T_SET_HIGH),
 				zap.Error(err),
 			)
+			errs = append(errs, fmt.Errorf("unable to upload %s thumbnail. %w", T_SET_HIGH, err))
 		}
 	}
 
 	// Max Resolution
 	if s.Thumbnail.Maxres.Path != "" {
-		tSetResponses[T_SET_MAXRES], err = u.uploadThumbnail(thumbnailSvc, videoId, s.Thumbnail.Maxres.Path)
+		tSetResponses[T_SET_MAXRES], err = u.uploadThumbnail(videoId, s.Thumbnail.Maxres.Path)
 		if err != nil {
 			logging.YLSLogger().Error("unable to upload thumbnail for live broadcast",
 				zap.String("broadcastId", videoId),
 				zap.String("thumbnail_type", T_SET_MAXRES),
 				zap.Error(err),
 			)
+			errs = append(errs, fmt.Errorf("unable to upload %s thumbnail. %w", T_SET_MAXRES, err))
 		}
 	}
 
@@ -183,118 +256,393 @@ func (u *StreamUploadClient) prepareThumbnails(s *Stream, b *youtube.LiveBroadca
 			Height: s.Thumbnail.Standard.Height,
 			Url:    defaultUrl(tSetResponses[T_SET_STANDARD]),
 		},
+	}, joinErrors(errs)
+}
+
+// mergeThumbnails replaces the thumbnails of a broadcast with the uploaded thumbnails of the same size
+func mergeThumbnails(current, uploaded *youtube.ThumbnailDetails) *youtube.ThumbnailDetails {
+	merged := &youtube.ThumbnailDetails{}
+	if current != nil {
+		*merged = *current
 	}
+	pick := func(c, u *youtube.Thumbnail) *youtube.Thumbnail {
+		if u != nil && u.Url != "" {
+			return u
+		}
+		return c
+	}
+	merged.Default = pick(merged.Default, uploaded.Default)
+	merged.High = pick(merged.High, uploaded.High)
+	merged.Maxres = pick(merged.Maxres, uploaded.Maxres)
+	merged.Medium = pick(merged.Medium, uploaded.Medium)
+	merged.Standard = pick(merged.Standard, uploaded.Standard)
+	return merged
+}
 
+// findOrCreateLiveStream looks up the LiveStream described by the stream configuration from the LiveStreams owned by the
+// authenticated channel. If none match and creation was requested, a new reusable LiveStream is created instead.
+func (u *StreamUploadClient) findOrCreateLiveStream(lc *StreamLiveStreamConfig) (*youtube.LiveStream, error) {
+	existing, err := u.backend.ListLiveStreams()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owned live streams. %w", err)
+	}
+
+	for _, ls := range existing {
+		if lc.Matches(ls) {
+			return ls, nil
+		}
+	}
+
+	if lc.Create == nil {
+		return nil, fmt.Errorf("no live stream exists matching %q and creation was not requested", lc.String())
+	}
+	if lc.Title == "" {
+		return nil, errors.New("a title is required to create a new live stream")
+	}
+
+	ls, err := u.backend.InsertLiveStream([]string{"snippet", "cdn", "contentDetails"}, lc.Make())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create live stream. %w", err)
+	}
+
+	logging.YLSLogger().Info("created new reusable live stream",
+		zap.String("liveStreamId", ls.Id),
+		zap.String("title", ls.Snippet.Title),
+	)
+	return ls, nil
 }
 
-func (u *StreamUploadClient) Upload(s *Stream) func() {
-	return func() {
-		if u.svc == nil {
-			logging.YLSLogger().Error("unable to create Live Broadcast resource. no service was available.")
-			return
+// bindLiveStream binds the broadcast to the LiveStream configured for the stream so that an encoder using the LiveStream
+// key will go live on this broadcast
+func (u *StreamUploadClient) bindLiveStream(s *Stream, b *youtube.LiveBroadcast) (*youtube.LiveStream, error) {
+	ls, err := u.findOrCreateLiveStream(s.LiveStream)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := u.backend.BindBroadcast(b.Id, ls.Id); err != nil {
+		return nil, fmt.Errorf("failed to bind live stream %s to broadcast %s. %w", ls.Id, b.Id, err)
+	}
+
+	logging.YLSLogger().Debug("bound live broadcast to live stream",
+		zap.String("broadcastId", b.Id),
+		zap.String("liveStreamId", ls.Id),
+	)
+	return ls, nil
+}
+
+// findOccurrence searches the upcoming broadcasts of the channel for the broadcast of an occurrence. No broadcast is
+// returned if the occurrence has not been scheduled yet
+func (u *StreamUploadClient) findOccurrence(key, title string, start time.Time) (*youtube.LiveBroadcast, error) {
+	upcoming, err := u.backend.ListBroadcasts(BROADCAST_STATUS_UPCOMING)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range upcoming {
+		if matchesOccurrence(b, key, title, start) {
+			return b, nil
 		}
+	}
+	return nil, nil
+}
 
-		liveBroadcast := &youtube.LiveBroadcast{
-			Snippet: &youtube.LiveBroadcastSnippet{
-				Title:              s.Title,
-				Description:        s.Description,
-				ScheduledStartTime: time.Now().Local().Add(time.Duration(s.StartDelaySeconds) * time.Second).Format(time.RFC3339),
-			},
-			Status: &youtube.LiveBroadcastStatus{
-				PrivacyStatus:           s.Privacy.Level,
-				SelfDeclaredMadeForKids: s.Privacy.SelfDeclaredMadeForKids,
-			},
-			ContentDetails: s.ContentDetails.Make(),
+// occurrenceNumber determines the 1-based number of an occurrence among the broadcasts created for a stream using the
+// state store. Occurrences that were already recorded keep their number
+func (u *StreamUploadClient) occurrenceNumber(streamName, key string) int {
+	n := 0
+	for _, r := range u.state.List(streamName) {
+		if r.OccurrenceKey == key && r.Occurrence > 0 {
+			return r.Occurrence
+		}
+		if r.Occurrence > n {
+			n = r.Occurrence
 		}
+	}
+	return n + 1
+}
 
-		if u.dryRun {
-			logging.YLSLogger().Info("would have created LiveBroadcast resource, but is dry-run",
-				zap.String("streamName", s.Name),
-				zap.String("title", liveBroadcast.Snippet.Title),
-				zap.String("description", liveBroadcast.Snippet.Description),
-				zap.String("scheduledStart", liveBroadcast.Snippet.ScheduledStartTime),
-				zap.String("privacyLevel", liveBroadcast.Status.PrivacyStatus),
-			)
-		} else {
-			liveBroadcastCall := u.svc.LiveBroadcasts.Insert([]string{"snippet", "status", "content_details"}, liveBroadcast)
-			broadcastResp, err := liveBroadcastCall.Do()
-			if err != nil {
-				logging.YLSLogger().Error("failed to create a live broadcast", zap.String("streamName", s.Name), zap.Error(err))
-				return
-			}
-
-			liveStreamCall := u.svc.LiveStreams.List([]string{"cdn"}).Mine(true)
-			existingLiveStreams, err := liveStreamCall.Do()
-			if err != nil {
-				logging.YLSLogger().Error("failed to get owned streams",
-					zap.String("streamName", s.Name),
-					zap.Error(err),
-				)
-			}
-
-			streamKeys := []string{}
-			for _, ls := range existingLiveStreams.Items {
-				streamKeys = append(streamKeys, ls.Cdn.IngestionInfo.StreamName)
-			}
-
-			// Unfortunately it seems that the API cannot determine which Stream key will be used until a LS and Broadcast are bound.
-			// Manually creating both can work, but will require a lot of extra detail in the configuration object which isn't ideal for users
-			// For now just keep in mind: https://stackoverflow.com/questions/33798901/youtube-api-v3-get-live-now-rtmp-and-streamkey
-			logging.YLSLogger().Info("created live scheduled broadcast",
-				zap.String("streamName", s.Name),
-				zap.String("broadcastName", broadcastResp.Snippet.Title),
-				zap.String("scheduledStart", broadcastResp.Snippet.ScheduledStartTime),
-				zap.String("currentStatus", broadcastResp.Status.RecordingStatus),
-				zap.Strings("validStreamKeys", streamKeys),
-				zap.String("shareableLink", fmt.Sprintf("https://youtube.com/live/%s?feature=share", broadcastResp.Id)),
-				zap.String("embedableLink", fmt.Sprintf("https://youtube.com/embed/%s", broadcastResp.Id)),
-			)
+// record persists the broadcast record to the state store. Failures are logged, but never interrupt a job
+func (u *StreamUploadClient) record(r *state.BroadcastRecord) {
+	if err := u.state.Put(r); err != nil {
+		logging.YLSLogger().Error("failed to record broadcast in state store",
+			zap.String("streamName", r.StreamName),
+			zap.String("broadcastId", r.BroadcastID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Upload runs the job of the stream for its current occurrence: the broadcast is created (or the existing broadcast of
+// the occurrence is reused), bound to its live stream, given its thumbnails and published. Failures of each step are
+// recorded in the returned JobResult, which is also logged and persisted to the state store
+func (u *StreamUploadClient) Upload(s *Stream) *state.JobResult {
+	res, _ := u.run(s, time.Now())
+	return res
+}
+
+// upload runs the job of the stream for the occurrence at t, also returning the error which stopped the job, if any.
+// When the scheduled start of the occurrence has already passed (such as for a re-attempt), the broadcast starts
+// StartDelaySeconds from now instead
+func (u *StreamUploadClient) upload(s *Stream, t time.Time) (*state.JobResult, error) {
+	res := &state.JobResult{
+		StreamName: s.Name,
+		DryRun:     u.dryRun,
+		StartedAt:  time.Now(),
+	}
+
+	if u.backend == nil {
+		err := errors.New("no backend was available")
+		logging.YLSLogger().Error("unable to create Live Broadcast resource. no backend was available.")
+		res.Step(JOB_STEP_PREPARE, err)
+		return res, err
+	}
+
+	loc, err := s.Location()
+	if err != nil {
+		logging.YLSLogger().Error("failed to load time zone for stream", zap.String("streamName", s.Name), zap.String("timezone", s.Timezone), zap.Error(err))
+		res.Step(JOB_STEP_PREPARE, err)
+		return res, err
+	}
+
+	slot, scheduledStart := s.Occurrence(t.In(loc))
+	if scheduledStart.Before(res.StartedAt) {
+		scheduledStart = res.StartedAt.In(loc).Add(time.Duration(s.StartDelaySeconds) * time.Second)
+	}
+	occurrenceKey := s.OccurrenceKey(slot)
+	occurrenceNumber := u.occurrenceNumber(s.Name, occurrenceKey)
+	res.OccurrenceKey = occurrenceKey
+
+	// from here on, the stream refers to the stream rendered for this occurrence
+	s, err = s.Render(&TemplateVars{
+		Name:       s.Name,
+		Start:      scheduledStart,
+		Occurrence: occurrenceNumber,
+		Vars:       s.Vars,
+	})
+	res.Step(JOB_STEP_PREPARE, err)
+	if err != nil {
+		logging.YLSLogger().Error("failed to render stream templates", zap.String("occurrence", occurrenceKey), zap.Error(err))
+		return res, err
+	}
+
+	liveBroadcast := &youtube.LiveBroadcast{
+		Snippet: &youtube.LiveBroadcastSnippet{
+			Title:              s.Title,
+			Description:        withOccurrenceTag(s.Description, occurrenceKey),
+			ScheduledStartTime: scheduledStart.Format(time.RFC3339),
+		},
+		Status: &youtube.LiveBroadcastStatus{
+			PrivacyStatus:           s.Privacy.Level,
+			SelfDeclaredMadeForKids: s.Privacy.SelfDeclaredMadeForKids,
+		},
+		ContentDetails: s.ContentDetails.Make(),
+	}
+
+	if u.dryRun {
+		logging.YLSLogger().Info("would have created LiveBroadcast resource, but is dry-run",
+			zap.String("streamName", s.Name),
+			zap.String("occurrence", occurrenceKey),
+			zap.String("title", liveBroadcast.Snippet.Title),
+			zap.String("description", liveBroadcast.Snippet.Description),
+			zap.String("scheduledStart", liveBroadcast.Snippet.ScheduledStartTime),
+			zap.String("privacyLevel", liveBroadcast.Status.PrivacyStatus),
+			zap.Stringer("liveStream", s.LiveStream),
+		)
+		for _, step := range []string{JOB_STEP_BROADCAST, JOB_STEP_LIVESTREAM, JOB_STEP_THUMBNAILS, JOB_STEP_UPDATE, JOB_STEP_PUBLISH} {
+			res.Skip(step)
+		}
+		return res, nil
+	}
+
+	broadcastResp, err := u.findOccurrence(occurrenceKey, s.Title, scheduledStart)
+	if err != nil {
+		logging.YLSLogger().Warn("unable to check for an existing broadcast for this occurrence. a new broadcast will be created",
+			zap.String("streamName", s.Name),
+			zap.String("occurrence", occurrenceKey),
+			zap.Error(err),
+		)
+	}
+	if broadcastResp != nil {
+		logging.YLSLogger().Info("a broadcast already exists for this occurrence. reusing the existing broadcast",
+			zap.String("streamName", s.Name),
+			zap.String("occurrence", occurrenceKey),
+			zap.String("broadcastId", broadcastResp.Id),
+		)
+	} else {
+		broadcastResp, err = u.backend.InsertBroadcast([]string{"snippet", "status", "content_details"}, liveBroadcast)
+		if err != nil {
+			logging.YLSLogger().Error("failed to create a live broadcast", zap.String("streamName", s.Name), zap.Error(err))
+			res.Step(JOB_STEP_BROADCAST, err)
+			return res, err
+		}
+	}
+	res.Step(JOB_STEP_BROADCAST, nil)
+	res.BroadcastID = broadcastResp.Id
+
+	rec, ok := u.state.Get(broadcastResp.Id)
+	if !ok {
+		start, _ := time.Parse(time.RFC3339, broadcastResp.Snippet.ScheduledStartTime)
+		rec = &state.BroadcastRecord{
+			StreamName:     s.Name,
+			BroadcastID:    broadcastResp.Id,
+			OccurrenceKey:  occurrenceKey,
+			Occurrence:     occurrenceNumber,
+			Title:          broadcastResp.Snippet.Title,
+			ScheduledStart: start,
+			Privacy:        broadcastResp.Status.PrivacyStatus,
+		}
+	}
+	u.record(rec)
 
-			// Upload and assign thumbnail to LiveBroadcast
-			logging.YLSLogger().Info("assigning configured thumbnails to published LiveBroadcast")
-			thumbnails := u.prepareThumbnails(s, broadcastResp)
-
-			liveBroadcastUpdateCall := u.svc.LiveBroadcasts.Update([]string{"snippet"}, &youtube.LiveBroadcast{
-				Id: broadcastResp.Id,
-				Snippet: &youtube.LiveBroadcastSnippet{
-					Title:              broadcastResp.Snippet.Title,
-					Description:        broadcastResp.Snippet.Description,
-					ScheduledStartTime: broadcastResp.Snippet.ScheduledStartTime,
-					Thumbnails:         thumbnails,
-				},
-			})
-			_, err = liveBroadcastUpdateCall.Do()
-			if err != nil {
-				logging.YLSLogger().Error("failed to update existing live broadcast with Thumbnail",
-					zap.String("broadcastId", broadcastResp.Id),
-					zap.String("streamName", s.Name),
-					zap.Error(err),
-				)
-			}
-
-			logging.YLSLogger().Info("uploaded and attached thumbnail to existing live broadcast successfully",
+	boundStreamKey := ""
+	if s.LiveStream != nil {
+		ls, err := u.bindLiveStream(s, broadcastResp)
+		res.Step(JOB_STEP_LIVESTREAM, err)
+		if err != nil {
+			logging.YLSLogger().Error("failed to bind live broadcast to the configured live stream",
 				zap.String("streamName", s.Name),
-				zap.String("broadcastName", broadcastResp.Snippet.Title),
-				zap.String("scheduledStart", broadcastResp.Snippet.ScheduledStartTime),
-				zap.String("currentStatus", broadcastResp.Status.RecordingStatus),
+				zap.String("broadcastId", broadcastResp.Id),
+				zap.String("liveStream", s.LiveStream.String()),
+				zap.Error(err),
 			)
+		} else if ls.Cdn != nil && ls.Cdn.IngestionInfo != nil {
+			boundStreamKey = ls.Cdn.IngestionInfo.StreamName
+		}
+	} else {
+		res.Skip(JOB_STEP_LIVESTREAM)
+	}
+
+	logging.YLSLogger().Info("created live scheduled broadcast",
+		zap.String("streamName", s.Name),
+		zap.String("broadcastName", broadcastResp.Snippet.Title),
+		zap.String("scheduledStart", broadcastResp.Snippet.ScheduledStartTime),
+		zap.String("currentStatus", broadcastResp.Status.RecordingStatus),
+		zap.String("boundStreamKey", boundStreamKey),
+		zap.String("shareableLink", ShareableLink(broadcastResp.Id)),
+		zap.String("embedableLink", EmbedableLink(broadcastResp.Id)),
+	)
+
+	// Upload and assign thumbnail to LiveBroadcast
+	logging.YLSLogger().Info("assigning configured thumbnails to published LiveBroadcast")
+	thumbnails, err := u.prepareThumbnails(s, broadcastResp)
+	if s.Thumbnail.configured() {
+		res.Step(JOB_STEP_THUMBNAILS, err)
+	} else {
+		res.Skip(JOB_STEP_THUMBNAILS)
+	}
+
+	_, err = u.backend.UpdateBroadcast([]string{"snippet"}, &youtube.LiveBroadcast{
+		Id: broadcastResp.Id,
+		Snippet: &youtube.LiveBroadcastSnippet{
+			Title:              broadcastResp.Snippet.Title,
+			Description:        broadcastResp.Snippet.Description,
+			ScheduledStartTime: broadcastResp.Snippet.ScheduledStartTime,
+			Thumbnails:         thumbnails,
+		},
+	})
+	res.Step(JOB_STEP_UPDATE, err)
+	if err != nil {
+		logging.YLSLogger().Error("failed to update existing live broadcast with Thumbnail",
+			zap.String("broadcastId", broadcastResp.Id),
+			zap.String("streamName", s.Name),
+			zap.Error(err),
+		)
+	} else {
+		// publishers are given the broadcast with the thumbnails it now has
+		broadcastResp.Snippet.Thumbnails = mergeThumbnails(broadcastResp.Snippet.Thumbnails, thumbnails)
+		logging.YLSLogger().Info("uploaded and attached thumbnail to existing live broadcast successfully",
+			zap.String("streamName", s.Name),
+			zap.String("broadcastName", broadcastResp.Snippet.Title),
+			zap.String("scheduledStart", broadcastResp.Snippet.ScheduledStartTime),
+			zap.String("currentStatus", broadcastResp.Status.RecordingStatus),
+		)
+	}
+
+	publishers := s.Publishers.On(pub.PUBLISH_ON_SUCCESS)
+	if len(publishers) == 0 {
+		logging.YLSLogger().Warn("no publisher config specified for stream. skipping stream publish. don't worry, the Youtube livestream was still created",
+			zap.Any("stream", s.Name),
+		)
+		res.Skip(JOB_STEP_PUBLISH)
+		return res, nil
+	}
 
-			if s.Publisher != nil {
-				p, err := s.Publisher.GetPublisher()
-				if err != nil {
-					logging.YLSLogger().Fatal("unable to publish using provided publisher config", zap.Error(err))
-				}
-
-				if err := p.Publish(broadcastResp, s); err != nil {
-					logging.YLSLogger().Fatal("unable to publish Youtube Live Broadcast to publish target", zap.Error(err))
-				}
-
-				logging.YLSLogger().Info("published stream to publish target using configured publisher", zap.Any("publisherName", s.Publisher))
-			} else {
-				logging.YLSLogger().Warn("no publisher config specified for stream. skipping stream publish. don't worry, the Youtube livestream was still created",
-					zap.Any("stream", s.Name),
-				)
-			}
+	for i, err := range u.publish(s, publishers, broadcastResp, rec) {
+		res.Step(JOB_STEP_PUBLISH+":"+publishers[i].String(), err)
+	}
+	return res, nil
+}
+
+// publish publishes the broadcast using the given publishers of the stream, at most PublishConcurrency at a time, and
+// records the outcome of each with the broadcast. The returned errors are in the order of the publishers
+func (u *StreamUploadClient) publish(s *Stream, publishers []*pub.PublisherConfig, b *youtube.LiveBroadcast, rec *state.BroadcastRecord) []error {
+	limit := int(s.PublishConcurrency)
+	if limit < 1 {
+		limit = 1
+	}
+
+	errs := make([]error, len(publishers))
+	results := make([]state.PublisherResult, len(publishers))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := range publishers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cfg *pub.PublisherConfig) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			var ref string
+			ref, errs[i] = publishTo(cfg, b, s, rec.PublisherRef(cfg.String()))
+			results[i] = publisherResult(cfg, ref, errs[i])
+		}(i, publishers[i])
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		rec.SetPublisher(result)
+	}
+	u.record(rec)
+	return errs
+}
+
+// publishTo publishes the broadcast using a single publisher. Publishers which refer to what they published are given
+// the ref of what they previously published for the broadcast, so they can update it. The new ref is returned
+func publishTo(cfg *pub.PublisherConfig, b *youtube.LiveBroadcast, s *Stream, ref string) (string, error) {
+	p, err := cfg.GetPublisher()
+	if err == nil {
+		if rp, ok := p.(pub.RefPublisher); ok {
+			ref, err = rp.PublishRef(b, s, ref)
+		} else {
+			err = p.Publish(b, s)
 		}
 	}
+	if err != nil {
+		logging.YLSLogger().Error("unable to publish Youtube Live Broadcast to publish target",
+			zap.String("streamName", s.Name),
+			zap.String("broadcastId", b.Id),
+			zap.Stringer("publisher", cfg),
+			zap.Error(err),
+		)
+		return ref, err
+	}
+
+	logging.YLSLogger().Info("published stream to publish target using configured publisher", zap.String("streamName", s.Name), zap.Stringer("publisher", cfg), zap.String("ref", ref))
+	return ref, nil
+}
+
+func publisherResult(cfg *pub.PublisherConfig, ref string, err error) state.PublisherResult {
+	result := state.PublisherResult{
+		Name:      cfg.String(),
+		Status:    state.PUBLISH_STATUS_SUCCEEDED,
+		Ref:       ref,
+		Timestamp: time.Now(),
+	}
+	if err != nil {
+		result.Status = state.PUBLISH_STATUS_FAILED
+		result.Error = err.Error()
+	}
+	return result
 }
@@ -0,0 +1,257 @@
+package stream
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+	"sykesdev.ca/yls/pkg/state"
+)
+
+// testUploaderOption adjusts the configuration of the uploader created by newTestUploader
+type testUploaderOption func(cfg *StreamUploaderConfig)
+
+func withDryRun() testUploaderOption {
+	return func(cfg *StreamUploaderConfig) { cfg.DryRunMode = true }
+}
+
+func withQuotaRetryAfter(d time.Duration) testUploaderOption {
+	return func(cfg *StreamUploaderConfig) {
+		retry := DefaultRetryPolicy
+		retry.QuotaRetryAfter = d
+		cfg.Retry = &retry
+	}
+}
+
+func newTestUploader(t *testing.T, opts ...testUploaderOption) (*StreamUploadClient, *FakeBackend, *state.Store) {
+	t.Helper()
+
+	st, err := state.Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("failed to open state store. %s", err)
+	}
+	fake := NewFakeBackend()
+	cfg := &StreamUploaderConfig{Backend: fake, State: st}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	u, err := New(cfg)
+	if err != nil {
+		t.Fatalf("failed to create uploader. %s", err)
+	}
+	return u, fake, st
+}
+
+func newTestStream() *Stream {
+	return &Stream{
+		Name:        "sunday-service",
+		Title:       "Sunday Service",
+		Description: "Join us live",
+		Schedule:    "0 9 * * 0",
+		Privacy:     StreamPrivacy{Level: "unlisted"},
+	}
+}
+
+func testLiveStream(title string) *youtube.LiveStream {
+	return &youtube.LiveStream{Snippet: &youtube.LiveStreamSnippet{Title: title}}
+}
+
+func stepStatus(res *state.JobResult, step string) string {
+	for _, s := range res.Steps {
+		if s.Step == step {
+			return s.Status
+		}
+	}
+	return ""
+}
+
+func writeTestThumbnail(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "thumbnail.jpg")
+	if err := os.WriteFile(path, []byte("jpeg"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUploadCreatesBroadcast(t *testing.T) {
+	u, fake, st := newTestUploader(t)
+	s := newTestStream()
+
+	res := u.Upload(s)
+	if res.Failed() {
+		t.Fatalf("expected the job to succeed, failed steps %v", res.FailedSteps())
+	}
+
+	broadcasts := fake.Broadcasts()
+	if len(broadcasts) != 1 {
+		t.Fatalf("expected 1 broadcast, got %d", len(broadcasts))
+	}
+	b := broadcasts[0]
+	if b.Id != res.BroadcastID {
+		t.Errorf("expected job result for broadcast %s, got %s", b.Id, res.BroadcastID)
+	}
+	if b.Snippet.Title != s.Title {
+		t.Errorf("expected title %q, got %q", s.Title, b.Snippet.Title)
+	}
+	if b.Status.PrivacyStatus != "unlisted" {
+		t.Errorf("expected privacy unlisted, got %q", b.Status.PrivacyStatus)
+	}
+	if key, _ := OccurrenceKeyOf(b); key != res.OccurrenceKey {
+		t.Errorf("expected broadcast tagged with occurrence %q, got %q", res.OccurrenceKey, key)
+	}
+	for _, step := range []string{JOB_STEP_LIVESTREAM, JOB_STEP_THUMBNAILS, JOB_STEP_PUBLISH} {
+		if got := stepStatus(res, step); got != state.STEP_STATUS_SKIPPED {
+			t.Errorf("expected step %s to be skipped, got %q", step, got)
+		}
+	}
+
+	rec, ok := st.Get(b.Id)
+	if !ok {
+		t.Fatalf("expected broadcast %s to be recorded in the state store", b.Id)
+	}
+	if rec.StreamName != s.Name || rec.Occurrence != 1 {
+		t.Errorf("expected record of the first occurrence of %s, got %+v", s.Name, rec)
+	}
+	if _, ok := st.Job(s.Name); !ok {
+		t.Errorf("expected the job result of %s to be recorded in the state store", s.Name)
+	}
+}
+
+func TestUploadDryRun(t *testing.T) {
+	u, fake, st := newTestUploader(t, withDryRun())
+	s := newTestStream()
+
+	res := u.Upload(s)
+	if res.Failed() || !res.DryRun {
+		t.Errorf("expected a successful dry-run job, got %+v", res)
+	}
+	if n := len(fake.Broadcasts()); n != 0 {
+		t.Errorf("expected no broadcasts in dry-run mode, got %d", n)
+	}
+	if n := len(st.List(s.Name)); n != 0 {
+		t.Errorf("expected no recorded broadcasts in dry-run mode, got %d", n)
+	}
+}
+
+func TestUploadReusesExistingOccurrence(t *testing.T) {
+	u, fake, _ := newTestUploader(t)
+	s := newTestStream()
+	s.LiveStream = &StreamLiveStreamConfig{Title: "Main Camera", Create: &StreamLiveStreamCreateConfig{}}
+
+	first := u.Upload(s)
+	second := u.Upload(s)
+	if first.Failed() || second.Failed() {
+		t.Fatalf("expected both jobs to succeed, failed steps %v and %v", first.FailedSteps(), second.FailedSteps())
+	}
+
+	if n := len(fake.Broadcasts()); n != 1 {
+		t.Fatalf("expected the occurrence to be reused for 1 broadcast, got %d", n)
+	}
+	if first.BroadcastID != second.BroadcastID {
+		t.Errorf("expected both jobs to use broadcast %s, got %s", first.BroadcastID, second.BroadcastID)
+	}
+	streams, _ := fake.ListLiveStreams()
+	if len(streams) != 1 {
+		t.Errorf("expected the live stream to be reused for 1 live stream, got %d", len(streams))
+	}
+}
+
+func TestUploadBindsLiveStream(t *testing.T) {
+	u, fake, _ := newTestUploader(t)
+	ls := fake.AddLiveStream(testLiveStream("Main Camera"))
+	s := newTestStream()
+	s.LiveStream = &StreamLiveStreamConfig{Title: "Main Camera"}
+
+	res := u.Upload(s)
+	if got := stepStatus(res, JOB_STEP_LIVESTREAM); got != state.STEP_STATUS_SUCCEEDED {
+		t.Fatalf("expected the live stream step to succeed, got %q", got)
+	}
+	if b := fake.Broadcast(res.BroadcastID); b.ContentDetails.BoundStreamId != ls.Id {
+		t.Errorf("expected broadcast bound to live stream %s, got %q", ls.Id, b.ContentDetails.BoundStreamId)
+	}
+}
+
+func TestUploadCreatesLiveStream(t *testing.T) {
+	u, fake, _ := newTestUploader(t)
+	s := newTestStream()
+	s.LiveStream = &StreamLiveStreamConfig{Title: "Main Camera", Create: &StreamLiveStreamCreateConfig{}}
+
+	res := u.Upload(s)
+	streams, _ := fake.ListLiveStreams()
+	if len(streams) != 1 {
+		t.Fatalf("expected 1 created live stream, got %d", len(streams))
+	}
+	if b := fake.Broadcast(res.BroadcastID); b.ContentDetails.BoundStreamId != streams[0].Id {
+		t.Errorf("expected broadcast bound to live stream %s, got %q", streams[0].Id, b.ContentDetails.BoundStreamId)
+	}
+}
+
+func TestUploadSetsThumbnails(t *testing.T) {
+	u, fake, _ := newTestUploader(t)
+	path := writeTestThumbnail(t)
+	s := newTestStream()
+	s.Thumbnail.Default.Path = path
+	s.Thumbnail.High.Path = path
+
+	res := u.Upload(s)
+	if got := stepStatus(res, JOB_STEP_THUMBNAILS); got != state.STEP_STATUS_SUCCEEDED {
+		t.Fatalf("expected the thumbnails step to succeed, got %q", got)
+	}
+	if n := len(fake.Thumbnails(res.BroadcastID)); n != 2 {
+		t.Errorf("expected 2 uploaded thumbnails, got %d", n)
+	}
+	b := fake.Broadcast(res.BroadcastID)
+	if b.Snippet.Thumbnails == nil || b.Snippet.Thumbnails.Default.Url == "" || b.Snippet.Thumbnails.High.Url == "" {
+		t.Errorf("expected the broadcast to be updated with the uploaded thumbnails, got %+v", b.Snippet.Thumbnails)
+	}
+}
+
+func TestUploadFailures(t *testing.T) {
+	failure := errors.New("backend unavailable")
+
+	tests := []struct {
+		name       string
+		op         string
+		liveStream bool
+		thumbnail  bool
+		failedStep string
+		broadcasts int
+	}{
+		{name: "insert broadcast", op: FAKE_OP_INSERT_BROADCAST, failedStep: JOB_STEP_BROADCAST, broadcasts: 0},
+		{name: "bind broadcast", op: FAKE_OP_BIND_BROADCAST, liveStream: true, failedStep: JOB_STEP_LIVESTREAM, broadcasts: 1},
+		{name: "list live streams", op: FAKE_OP_LIST_LIVESTREAMS, liveStream: true, failedStep: JOB_STEP_LIVESTREAM, broadcasts: 1},
+		{name: "set thumbnail", op: FAKE_OP_SET_THUMBNAIL, thumbnail: true, failedStep: JOB_STEP_THUMBNAILS, broadcasts: 1},
+		{name: "update broadcast", op: FAKE_OP_UPDATE_BROADCAST, failedStep: JOB_STEP_UPDATE, broadcasts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, fake, _ := newTestUploader(t)
+			s := newTestStream()
+			if tt.liveStream {
+				fake.AddLiveStream(testLiveStream("Main Camera"))
+				s.LiveStream = &StreamLiveStreamConfig{Title: "Main Camera"}
+			}
+			if tt.thumbnail {
+				s.Thumbnail.Default.Path = writeTestThumbnail(t)
+			}
+
+			fake.FailNext(tt.op, failure)
+			res := u.Upload(s)
+
+			if got := stepStatus(res, tt.failedStep); got != state.STEP_STATUS_FAILED {
+				t.Errorf("expected step %s to fail, got %q", tt.failedStep, got)
+			}
+			if failed := res.FailedSteps(); len(failed) != 1 {
+				t.Errorf("expected only step %s to fail, got %v", tt.failedStep, failed)
+			}
+			if n := len(fake.Broadcasts()); n != tt.broadcasts {
+				t.Errorf("expected %d broadcasts, got %d", tt.broadcasts, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,524 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"gopkg.in/yaml.v3"
+	"sykesdev.ca/yls/pkg/helper"
+	"sykesdev.ca/yls/pkg/pub"
+	"sykesdev.ca/yls/pkg/schema"
+)
+
+// MAX_THUMBNAIL_BYTES is the largest thumbnail image accepted by YouTube
+const MAX_THUMBNAIL_BYTES = 2 * 1024 * 1024
+
+var (
+	unmarshalerType   = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+	publisherType     = reflect.TypeOf(pub.PublisherConfig{})
+	linePattern       = regexp.MustCompile(`line (\d+)`)
+	linePrefixPattern = regexp.MustCompile(`^line \d+: `)
+	yaml11Bools       = []string{"y", "yes", "n", "no", "on", "off"}
+)
+
+// ValidationError is a single problem found in a streams configuration file
+type ValidationError struct {
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+// ValidationErrors are all the problems found in a streams configuration file
+type ValidationErrors []*ValidationError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(ve))
+	for _, e := range ve {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// LoadFile reads, strictly decodes and validates a streams configuration file. If the file is invalid, the returned
+// error is a ValidationErrors describing every problem that was found
+func LoadFile(path string) (*StreamList, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Validate(b)
+}
+
+// Validate resolves the environment variable and file references of a streams configuration, then strictly decodes
+// and validates it. Unknown fields, values of the wrong type, values
+// outside of those allowed by YouTube, missing or oversized thumbnail files, invalid cron specs, time zones and
+// templates are all reported with their line and column
+func Validate(b []byte) (*StreamList, error) {
+	v := &validator{nodes: map[string]*yaml.Node{}}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		v.addLine(err.Error())
+		return nil, v.errs
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		v.errs = append(v.errs, &ValidationError{Line: 1, Column: 1, Message: "the streams configuration is empty"})
+		return nil, v.errs
+	}
+	v.interpolate(&root, "", map[*yaml.Node]bool{})
+	v.walk(root.Content[0], reflect.TypeOf(StreamList{}), "")
+
+	// the walk has already reported unknown fields, so the interpolated document can be decoded without KnownFields
+	var streams StreamList
+	if err := root.Decode(&streams); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			v.addLine(err.Error())
+			return nil, v.errs
+		}
+		// the walk has reported most type errors with a column already, so only add those it could not find
+		for _, msg := range typeErr.Errors {
+			if !v.hasLine(msg) {
+				v.addLine(msg)
+			}
+		}
+	}
+
+	streams.ApplyDefaults()
+	v.checkStreams(&streams)
+
+	if len(v.errs) > 0 {
+		sort.SliceStable(v.errs, func(i, j int) bool {
+			if v.errs[i].Line != v.errs[j].Line {
+				return v.errs[i].Line < v.errs[j].Line
+			}
+			return v.errs[i].Column < v.errs[j].Column
+		})
+		return nil, v.errs
+	}
+	return &streams, nil
+}
+
+// validator collects the problems found in a streams configuration and keeps track of the YAML node of every field so
+// that problems can be reported with a position
+type validator struct {
+	nodes map[string]*yaml.Node
+	errs  ValidationErrors
+}
+
+func (v *validator) addf(path string, format string, args ...interface{}) {
+	line, column := v.position(path)
+	v.errs = append(v.errs, &ValidationError{
+		Line:    line,
+		Column:  column,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// addLine adds an error reported by the YAML decoder, which only carries a line number
+func (v *validator) addLine(msg string) {
+	line := 0
+	if m := linePattern.FindStringSubmatch(msg); m != nil {
+		line, _ = strconv.Atoi(m[1])
+	}
+	v.errs = append(v.errs, &ValidationError{
+		Line:    line,
+		Column:  1,
+		Message: strings.TrimPrefix(msg, "yaml: "),
+	})
+}
+
+func (v *validator) hasLine(msg string) bool {
+	m := linePattern.FindStringSubmatch(msg)
+	if m == nil {
+		return false
+	}
+	line, _ := strconv.Atoi(m[1])
+	for _, e := range v.errs {
+		if e.Line == line {
+			return true
+		}
+	}
+	return false
+}
+
+// position finds the position of the field at path, or of its closest parent that is present in the file
+func (v *validator) position(path string) (int, int) {
+	for p := path; ; p = parentPath(p) {
+		if n, ok := v.nodes[p]; ok {
+			return n.Line, n.Column
+		}
+		if p == "" {
+			return 0, 0
+		}
+	}
+}
+
+func (v *validator) has(path string) bool {
+	_, ok := v.nodes[path]
+	return ok
+}
+
+func (v *validator) isList(path string) bool {
+	n, ok := v.nodes[path]
+	return ok && n.Kind == yaml.SequenceNode
+}
+
+func parentPath(path string) string {
+	i := strings.LastIndexAny(path, ".[")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// walk checks the node against the Go type it is decoded into, reporting unknown fields, values of the wrong type and
+// the missing required fields and invalid enum values declared by the schema annotations of the type
+func (v *validator) walk(n *yaml.Node, t reflect.Type, path string) {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	v.nodes[path] = n
+
+	if n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null" {
+		return
+	}
+	if t == publisherType {
+		v.walkPublisher(n, path)
+		return
+	}
+	if t.Kind() == reflect.Slice && n.Kind == yaml.MappingNode && reflect.PtrTo(t).Implements(unmarshalerType) {
+		// lists which decode themselves (such as publishers) accept a single mapping as a list of one
+		v.walk(n, t.Elem(), path)
+		return
+	}
+	if t.Kind() != reflect.Slice && (t.Implements(unmarshalerType) || reflect.PtrTo(t).Implements(unmarshalerType)) {
+		v.walkUnmarshaler(n, t, path)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		v.walk(n, t.Elem(), path)
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			v.addf(path, "expected a mapping")
+			return
+		}
+		fields := schema.YAMLFields(t)
+		present := map[string]bool{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			fieldPath := joinPath(path, key.Value)
+			ft, ok := fields[key.Value]
+			if !ok {
+				v.nodes[fieldPath] = key
+				v.addf(fieldPath, "unknown field %q", key.Value)
+				continue
+			}
+			v.walk(value, ft, fieldPath)
+
+			if value.Kind == yaml.ScalarNode && value.ShortTag() != "!!null" && value.Value != "" {
+				present[key.Value] = true
+				if f, ok := schema.Lookup(t, key.Value); ok {
+					v.checkEnum(fieldPath, value.Value, f.Enum)
+				}
+			} else if value.Kind == yaml.MappingNode || value.Kind == yaml.SequenceNode {
+				present[key.Value] = true
+			}
+		}
+		for _, name := range schema.Required(t) {
+			if !present[name] {
+				v.addf(joinPath(path, name), "missing required field %q", name)
+			}
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			v.addf(path, "expected a mapping")
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v.walk(n.Content[i+1], t.Elem(), joinPath(path, n.Content[i].Value))
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			v.addf(path, "expected a list")
+			return
+		}
+		for i, item := range n.Content {
+			v.walk(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Bool:
+		if n.Kind != yaml.ScalarNode || (n.ShortTag() != "!!bool" && !helper.StringInSlice(strings.ToLower(n.Value), yaml11Bools)) {
+			v.addf(path, "expected a boolean but got %q", n.Value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!int" {
+			v.addf(path, "expected an integer but got %q", n.Value)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n.Kind != yaml.ScalarNode || n.ShortTag() != "!!int" || strings.HasPrefix(n.Value, "-") {
+			v.addf(path, "expected a positive integer but got %q", n.Value)
+			return
+		}
+		if _, err := strconv.ParseUint(n.Value, 0, t.Bits()); err != nil {
+			v.addf(path, "value %s is out of range (at most %d bits)", n.Value, t.Bits())
+		}
+	case reflect.Float32, reflect.Float64:
+		if n.Kind != yaml.ScalarNode || (n.ShortTag() != "!!int" && n.ShortTag() != "!!float") {
+			v.addf(path, "expected a number but got %q", n.Value)
+		}
+	case reflect.String:
+		if n.Kind != yaml.ScalarNode {
+			v.addf(path, "expected a string")
+		}
+	}
+}
+
+// walkUnmarshaler decodes a node into a type which decodes itself, so that its errors are reported with a column
+func (v *validator) walkUnmarshaler(n *yaml.Node, t reflect.Type, path string) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	err := n.Decode(reflect.New(t).Interface())
+	var typeErr *yaml.TypeError
+	switch {
+	case errors.As(err, &typeErr):
+		for _, msg := range typeErr.Errors {
+			v.addf(path, "%s", linePrefixPattern.ReplaceAllString(msg, ""))
+		}
+	case err != nil:
+		v.addf(path, "%s", err)
+	}
+}
+
+// walkPublisher checks a publisher entry. The configuration under the key of its type is walked using the configuration
+// type registered for the publisher type, if any
+func (v *validator) walkPublisher(n *yaml.Node, path string) {
+	if n.Kind != yaml.MappingNode {
+		v.addf(path, "expected a mapping")
+		return
+	}
+
+	kind := ""
+	configs := []*yaml.Node{}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		fieldPath := joinPath(path, key.Value)
+		v.nodes[fieldPath] = value
+		switch key.Value {
+		case "type":
+			kind = value.Value
+		case "name":
+			if value.Kind != yaml.ScalarNode {
+				v.addf(fieldPath, "expected a string")
+			}
+		case "on":
+			v.walk(value, reflect.TypeOf([]string{}), fieldPath)
+			if value.Kind == yaml.SequenceNode {
+				for i, item := range value.Content {
+					v.checkEnum(fmt.Sprintf("%s[%d]", fieldPath, i), item.Value, pub.PUBLISH_ON_ALLOWED)
+				}
+			}
+		default:
+			configs = append(configs, key, value)
+		}
+	}
+	if kind == "" && len(configs) == 2 {
+		kind = configs[0].Value
+	}
+
+	r, ok := pub.Lookup(kind)
+	switch {
+	case kind == "":
+		v.addf(path, "unknown publisher. must specify one of [%s]", strings.Join(pub.Types(), ", "))
+		return
+	case !ok && v.has(joinPath(path, "type")):
+		v.checkEnum(joinPath(path, "type"), kind, pub.Types())
+		return
+	case !ok:
+		v.addf(joinPath(path, kind), "unknown publisher type %q. must be one of [%s]", kind, strings.Join(pub.Types(), ", "))
+		return
+	}
+
+	found := false
+	for i := 0; i+1 < len(configs); i += 2 {
+		key, value := configs[i], configs[i+1]
+		fieldPath := joinPath(path, key.Value)
+		if key.Value != kind {
+			v.nodes[fieldPath] = key
+			v.addf(fieldPath, "unknown field %q", key.Value)
+			continue
+		}
+		found = true
+		if r.ConfigType != nil {
+			v.walk(value, r.ConfigType, fieldPath)
+		} else if _, err := r.Factory(value); err != nil {
+			v.addf(fieldPath, "%s", linePrefixPattern.ReplaceAllString(err.Error(), ""))
+		}
+	}
+	if !found {
+		v.addf(joinPath(path, kind), "missing required field %q", kind)
+	}
+}
+
+func (v *validator) checkEnum(path, value string, allowed []string) {
+	if value != "" && len(allowed) > 0 && !helper.StringInSlice(value, allowed) {
+		v.addf(path, "invalid value %q. must be one of [%s]", value, strings.Join(allowed, ", "))
+	}
+}
+
+func (v *validator) checkTemplate(path, text string) {
+	if _, err := template.New(path).Funcs(sprig.TxtFuncMap()).Parse(text); err != nil {
+		v.addf(path, "invalid template. %s", err)
+	}
+}
+
+func (v *validator) checkStreams(sl *StreamList) {
+	if _, err := sl.Location(); err != nil {
+		v.addf("timezone", "invalid time zone %q. %s", sl.Timezone, err)
+	}
+	if len(sl.Items) == 0 && v.has("streams") {
+		v.addf("streams", "must specify at least one stream configuration")
+	}
+
+	accounts := map[string]bool{}
+	for i, a := range sl.Accounts {
+		path := fmt.Sprintf("accounts[%d]", i)
+		if a.Name != "" && accounts[a.Name] {
+			v.addf(joinPath(path, "name"), "account names must be unique. %q is configured more than once", a.Name)
+		}
+		accounts[a.Name] = true
+		if a.OauthConfig == "" && a.Credentials == "" {
+			v.addf(path, "an oauthConfig or credentials file is required")
+		}
+	}
+
+	names := map[string]bool{}
+	for i := range sl.Items {
+		path := fmt.Sprintf("streams[%d]", i)
+		s := &sl.Items[i]
+		if s.Name != "" && names[s.Name] {
+			v.addf(joinPath(path, "name"), "stream names must be unique. %q is configured more than once", s.Name)
+		}
+		names[s.Name] = true
+		if s.Account != "" && !accounts[s.Account] {
+			v.addf(joinPath(path, "account"), "no account named %q is configured", s.Account)
+		}
+		v.checkStream(path, s)
+	}
+}
+
+func (v *validator) checkStream(path string, s *Stream) {
+	// the top-level time zone was already checked, so only report time zones set on the stream itself
+	tzValid := true
+	if _, err := s.Location(); err != nil {
+		tzValid = false
+		if v.has(joinPath(path, "timezone")) {
+			v.addf(joinPath(path, "timezone"), "invalid time zone %q. %s", s.Timezone, err)
+		}
+	}
+	// a missing schedule is reported as a missing required field
+	if _, err := s.JobSchedule(); s.Schedule != "" && err != nil && tzValid {
+		v.addf(joinPath(path, "schedule"), "invalid cron schedule %q. %s", s.Schedule, err)
+	}
+	if s.EndSchedule != "" && tzValid {
+		if _, err := s.ParseSpec(s.EndSchedule); err != nil {
+			v.addf(joinPath(path, "endSchedule"), "invalid cron schedule %q. %s", s.EndSchedule, err)
+		}
+	}
+	if s.CreateAhead.Duration < 0 {
+		v.addf(joinPath(path, "createAhead"), "must not be negative")
+	}
+
+	v.checkTemplate(joinPath(path, "title"), s.Title)
+	v.checkTemplate(joinPath(path, "description"), s.Description)
+
+	for _, t := range s.Thumbnail.paths() {
+		if *t.value != "" {
+			v.checkThumbnail(joinPath(path, "thumbnails."+t.name+".path"), *t.value)
+		}
+	}
+
+	if lc := s.LiveStream; lc != nil {
+		lsPath := joinPath(path, "liveStream")
+		if lc.StreamKey == "" && lc.Title == "" {
+			v.addf(lsPath, "a streamKey or title is required to identify the live stream")
+		}
+		if lc.Create != nil && lc.Title == "" {
+			v.addf(lsPath, "a title is required to create a new live stream")
+		}
+	}
+
+	publishers := map[string]bool{}
+	for i := range s.Publishers {
+		p := &s.Publishers[i]
+		pubPath := joinPath(path, "publisher")
+		if len(s.Publishers) > 1 || v.isList(pubPath) {
+			pubPath = fmt.Sprintf("%s[%d]", pubPath, i)
+		}
+		if publishers[p.String()] {
+			v.addf(pubPath, "publisher names must be unique within a stream. %q is configured more than once, so set a name for each", p.String())
+		}
+		publishers[p.String()] = true
+		if p.Config == nil {
+			// the walk has already reported why the publisher could not be decoded
+			continue
+		}
+
+		for _, e := range p.Validate() {
+			fieldPath := pubPath
+			if e.Field != "" {
+				fieldPath = joinPath(fieldPath, e.Field)
+			}
+			v.addf(fieldPath, "%s", e.Message)
+		}
+	}
+}
+
+// checkThumbnail verifies that a thumbnail exists and can be uploaded. Templated paths can only be checked for syntax
+// since they depend on the occurrence being scheduled
+func (v *validator) checkThumbnail(path, thumbnailPath string) {
+	if strings.Contains(thumbnailPath, "{{") {
+		v.checkTemplate(path, thumbnailPath)
+		return
+	}
+
+	info, err := os.Stat(thumbnailPath)
+	if err != nil {
+		v.addf(path, "unable to read thumbnail. %s", err)
+		return
+	}
+	if info.IsDir() {
+		v.addf(path, "thumbnail %s is a directory", thumbnailPath)
+		return
+	}
+	if info.Size() > MAX_THUMBNAIL_BYTES {
+		v.addf(path, "thumbnail %s is %d bytes. YouTube accepts thumbnails of at most %d bytes", thumbnailPath, info.Size(), MAX_THUMBNAIL_BYTES)
+	}
+}
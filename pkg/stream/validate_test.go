@@ -0,0 +1,222 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validationOutput validates the streams configuration as the validate command does and returns the problems it
+// reports, one "file:line:col: path: message" line each. THUMBNAILS in the configuration is replaced by the directory
+// of the test thumbnails
+func validationOutput(t *testing.T, config string) []string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "small.jpg"), []byte("jpeg"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "large.jpg"), make([]byte, MAX_THUMBNAIL_BYTES+1), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "streams.yaml")
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(config, "THUMBNAILS", dir)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadFile(path)
+	if err == nil {
+		return nil
+	}
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected validation errors, got %s", err)
+	}
+	out := []string{}
+	for _, e := range verrs {
+		out = append(out, strings.ReplaceAll(fmt.Sprintf("streams.yaml:%s", e), dir, "THUMBNAILS"))
+	}
+	return out
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{
+			name: "valid",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * * 0"
+    thumbnails:
+      default:
+        path: THUMBNAILS/small.jpg
+`,
+		},
+		{
+			name: "unknown fields",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * * 0"
+    shedule: "0 10 * * 0"
+    privacy:
+      lvl: public
+`,
+			want: []string{
+				`streams.yaml:7:5: streams[0].shedule: unknown field "shedule"`,
+				`streams.yaml:9:7: streams[0].privacy.lvl: unknown field "lvl"`,
+			},
+		},
+		{
+			name: "enums",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * * 0"
+    privacy:
+      level: hidden
+    contentDetails:
+      latencyPreference: fast
+`,
+			want: []string{
+				`streams.yaml:8:14: streams[0].privacy.level: invalid value "hidden". must be one of [private, unlisted, public]`,
+				`streams.yaml:10:26: streams[0].contentDetails.latencyPreference: invalid value "fast". must be one of [normal, low, ultraLow]`,
+			},
+		},
+		{
+			name: "wrong types",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * * 0"
+    delaySeconds: soon
+`,
+			want: []string{
+				`streams.yaml:7:19: streams[0].delaySeconds: expected a positive integer but got "soon"`,
+			},
+		},
+		{
+			name: "duplicate names",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * * 0"
+  - name: sunday
+    title: Sunday Evening
+    description: Join us
+    schedule: "0 18 * * 0"
+`,
+			want: []string{
+				`streams.yaml:7:11: streams[1].name: stream names must be unique. "sunday" is configured more than once`,
+			},
+		},
+		{
+			name: "thumbnails",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * * 0"
+    thumbnails:
+      default:
+        path: THUMBNAILS/missing.jpg
+      high:
+        path: THUMBNAILS/large.jpg
+      medium:
+        path: THUMBNAILS
+`,
+			want: []string{
+				`streams.yaml:9:15: streams[0].thumbnails.default.path: unable to read thumbnail. stat THUMBNAILS/missing.jpg: no such file or directory`,
+				`streams.yaml:11:15: streams[0].thumbnails.high.path: thumbnail THUMBNAILS/large.jpg is 2097153 bytes. YouTube accepts thumbnails of at most 2097152 bytes`,
+				`streams.yaml:13:15: streams[0].thumbnails.medium.path: thumbnail THUMBNAILS is a directory`,
+			},
+		},
+		{
+			name: "invalid schedule",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * *"
+`,
+			want: []string{
+				`streams.yaml:6:15: streams[0].schedule: invalid cron schedule "0 9 * *". expected exactly 5 fields, found 4: [0 9 * *]`,
+			},
+		},
+		{
+			name: "schedule not checked with an invalid stream time zone",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * *"
+    endSchedule: "0 11 * *"
+    timezone: America/Nowhere
+`,
+			want: []string{
+				`streams.yaml:8:15: streams[0].timezone: invalid time zone "America/Nowhere". unknown time zone America/Nowhere`,
+			},
+		},
+		{
+			name: "schedule not checked with an invalid top-level time zone",
+			config: `
+timezone: America/Nowhere
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * *"
+`,
+			want: []string{
+				`streams.yaml:2:11: timezone: invalid time zone "America/Nowhere". unknown time zone America/Nowhere`,
+			},
+		},
+		{
+			name: "legacy wordpress content",
+			config: `
+streams:
+  - name: sunday
+    title: Sunday Service
+    description: Join us
+    schedule: "0 9 * * 0"
+    publisher:
+      wordpress:
+        host: example.com
+        username: yls
+        appToken: token
+        content: "{{ .Broadcast.Snippet.Title }}"
+`,
+			want: []string{
+				`streams.yaml:12:9: streams[0].publisher.wordpress.content: unknown field "content"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validationOutput(t, tt.config)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expected\n%s\ngot\n%s", strings.Join(tt.want, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}